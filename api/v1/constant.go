@@ -1,15 +1,73 @@
 package v1
 
 const (
-	StatefulsetName      = "%s-statefulset"
-	ConfigMapName        = "%s-configmap"
-	HeadlessServiceName  = "%s-headless-service"
-	StorageValueUnit     = "%d%s"
-	ConfigVolumeName     = "config"
-	StorageContainerName = "storage"
-	TrackerContainerName = "tracker"
-	PvcName              = "fastdfs-storage-data"
-	DataDir              = "/data"
+	TrackerStatefulSetName       = "%s-tracker"
+	StorageStatefulSetName       = "%s-storage-%s"
+	ConfigMapName                = "%s-configmap"
+	HTTPConfigSecretName         = "%s-http-config"
+	AntiStealSecretName          = "%s-anti-steal"
+	AntiStealSecretKey           = "secret"
+	RotationRequestKey           = "rotation-request"
+	PreviousSecretKey            = "previous-secret"
+	PreviousKeyExpirationKey     = "previous-secret-expiration"
+	PreviousHTTPConfigFile       = "http-previous.conf"
+	HeadlessServiceName          = "%s-%s-headless"
+	TrackerServiceName           = "%s-tracker"
+	ExternalServiceName          = "%s-external"
+	GatewayName                  = "%s-gateway"
+	GroupGatewayName             = "%s-gateway-%s"
+	GatewayTLSSecretName         = "%s-gateway-tls"
+	NetworkPolicyName            = "%s-%s"
+	GroupNetworkPolicyName       = "%s-%s-%s"
+	StorageValueUnit             = "%d%s"
+	ConfigVolumeName             = "config"
+	StorageContainerName         = "storage"
+	TrackerContainerName         = "tracker"
+	GatewayContainerName         = "gateway"
+	PreviousGatewayContainerName = "gateway-previous"
+	HTTPPortName                 = "http"
+	PvcName                      = "fastdfs-storage-data"
+	DataVolumeName               = "data"
+	DataDir                      = "/data"
+	ConfigDir                    = "/etc/fdfs"
+	TrackerConfigFile            = "tracker.conf"
+	StorageConfigFile            = "storage.conf"
+	GroupStorageConfigFile       = "storage-%s.conf"
+	ClientConfigFile             = "client.conf"
+	HTTPConfigFile               = "http.conf"
+	ModFastDFSConfigFile         = "mod_fastdfs.conf"
+	GroupModFastDFSConfigFile    = "mod_fastdfs-%s.conf"
+	NginxConfigFile              = "nginx.conf"
+	PreviousNginxConfigFile      = "nginx-previous.conf"
+	NginxBinary                  = "/usr/local/nginx/sbin/nginx"
+	StorageIdsConfigFile         = "storage_ids.conf"
+	StorageIdsVolumeName         = "storage-ids"
+	StorageIdsDir                = "/etc/fdfs/ids"
+	NodeConfigFile               = "node.conf"
+)
+
+const (
+	VersionAnnotation    = "fastdfs.beordie.cn/version"
+	ConfigHashAnnotation = "fastdfs.beordie.cn/config-hash"
+
+	// IssuerAnnotation and ClusterIssuerAnnotation ask cert-manager to issue the certificate of an ingress
+	IssuerAnnotation        = "cert-manager.io/issuer"
+	ClusterIssuerAnnotation = "cert-manager.io/cluster-issuer"
+
+	// RotateAntiStealKeyAnnotation rotates the generated anti-steal key whenever its value changes
+	RotateAntiStealKeyAnnotation = "fastdfs.beordie.cn/rotate-anti-steal-key"
+)
+
+const (
+	RoleLabelKey  = "role"
+	GroupLabelKey = "group"
+
+	EnvTrackerServer = "TRACKER_SERVER"
+	EnvPort          = "PORT"
+	EnvGroupName     = "GROUP_NAME"
+	EnvPodIP         = "POD_IP"
+	EnvNodeIP        = "NODE_IP"
+	EnvGatewayPort   = "GATEWAY_PORT"
 )
 
 const (
@@ -20,7 +78,38 @@ const (
 const (
 	ScheduleTypeAnnotationValueIgnore = "ignore"
 
-	DefaultStoragePort = 22122
-	DefaultTrackerPort = 23000
-	DefaultDHTPort     = 11411
+	DefaultTrackerPort     = 22122
+	DefaultStoragePort     = 23000
+	DefaultStorageHTTPPort = 8888
+	DefaultDHTPort         = 11411
+
+	// DefaultGatewayPort is the port gateways serve downloads on, PreviousGatewayPort is the
+	// loopback port of the nginx checking tokens against the previous anti-steal key
+	DefaultGatewayPort     = 80
+	PreviousGatewayPort    = 8081
+	DefaultGatewayReplicas = 1
+
+	DefaultReplicas         = 1
+	DefaultTrackerReplicas  = 1
+	DefaultServerAuthTTL    = 600
+	MaxServerAuthSecretSize = 128
+	DefaultStorageUnit      = "Gi"
+	DefaultImageName        = "fastdfs"
+	DefaultStorageGroupName = "group1"
+
+	// StorageIDOffset and StorageIDBlockSize lay out storage ids, the n-th group
+	// owns the ids from StorageIDOffset + n * StorageIDBlockSize + 1
+	StorageIDOffset    = 100000
+	StorageIDBlockSize = 1000
+
+	// MultiIPVersion is the first FastDFS major release advertising several addresses per storage server
+	MultiIPVersion = 6
+)
+
+var (
+	// UnsupportedVersions is the FastDFS releases the operator refuses to deploy
+	UnsupportedVersions = []string{"3.6.3"}
+
+	DefaultTrackerCommand = []string{"/usr/bin/start.sh", "tracker"}
+	DefaultStorageCommand = []string{"/usr/bin/start.sh", "storage"}
 )
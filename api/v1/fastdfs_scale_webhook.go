@@ -0,0 +1,70 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const scaleWebhookPath = "/validate-fastdfs-beordie-cn-v1-fastdfs-scale"
+
+//+kubebuilder:webhook:path=/validate-fastdfs-beordie-cn-v1-fastdfs-scale,mutating=false,failurePolicy=fail,sideEffects=None,groups=fastdfs.beordie.cn,resources=fastdfses/scale,verbs=update,versions=v1,name=vfastdfsscale.kb.io,admissionReviewVersions=v1
+
+// scaleValidator rejects scaling clusters whose spec.replicas does not size every storage
+// server, the scale subresource would report more pods than it asked for and autoscalers
+// would never settle
+type scaleValidator struct {
+	client client.Client
+}
+
+// Handle implements admission.Handler for updates of the scale subresource
+func (v *scaleValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	fastdfslog.Info("validate scale", "name", req.Name)
+
+	scale := &autoscalingv1.Scale{}
+	if err := json.Unmarshal(req.Object.Raw, scale); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	cluster := &FastDFS{}
+	if err := v.client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, cluster); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if cluster.Spec.Replicas != nil && *cluster.Spec.Replicas == scale.Spec.Replicas {
+		return admission.Allowed("")
+	}
+	if !cluster.IsScalable() {
+		return admission.Denied("only clusters with a single storage group following spec.replicas can be scaled, " +
+			"set the replicas of each storage group instead")
+	}
+
+	// the scale subresource bypasses the validation of the cluster
+	replicas := scale.Spec.Replicas
+	cluster.Spec.Replicas = &replicas
+	if allErrs := cluster.validateReplicas(field.NewPath("spec")); len(allErrs) != 0 {
+		return admission.Denied(allErrs.ToAggregate().Error())
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// clusterClient serves a single cluster to the scale validator
+type clusterClient struct {
+	client.Client
+	cluster *FastDFS
+}
+
+func (c *clusterClient) Get(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+	c.cluster.DeepCopyInto(obj.(*FastDFS))
+	return nil
+}
+
+func TestScaleValidatorHandle(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(cluster *FastDFS)
+		replicas int32
+		allowed  bool
+	}{
+		{"scale up", func(cluster *FastDFS) {}, 3, true},
+		{"scale down", func(cluster *FastDFS) {
+			cluster.Spec.Replicas = int32Ptr(3)
+			cluster.Spec.ParticipantReplicas = int32Ptr(1)
+		}, 2, true},
+		{"unchanged", func(cluster *FastDFS) {
+			cluster.Spec.Storage.Groups = []StorageGroup{{Name: "group1"}, {Name: "group2"}}
+		}, 1, true},
+		{"several groups", func(cluster *FastDFS) {
+			cluster.Spec.Storage.Groups = []StorageGroup{{Name: "group1"}, {Name: "group2"}}
+		}, 2, false},
+		{"group with its own replicas", func(cluster *FastDFS) {
+			cluster.Spec.Storage.Groups = []StorageGroup{{Name: "group1", Replicas: int32Ptr(1)}}
+		}, 2, false},
+		{"single group following replicas", func(cluster *FastDFS) {
+			cluster.Spec.Storage.Groups = []StorageGroup{{Name: "data"}}
+		}, 2, true},
+		{"beyond the storage id block", func(cluster *FastDFS) {}, StorageIDBlockSize, false},
+		{"below participant replicas", func(cluster *FastDFS) {
+			cluster.Spec.Replicas = int32Ptr(3)
+			cluster.Spec.ParticipantReplicas = int32Ptr(3)
+		}, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster()
+			tt.mutate(cluster)
+			raw, err := json.Marshal(&autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: tt.replicas}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			validator := &scaleValidator{client: &clusterClient{cluster: cluster}}
+			resp := validator.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Name:      cluster.Name,
+				Namespace: cluster.Namespace,
+				Object:    runtime.RawExtension{Raw: raw},
+			}})
+			if resp.Allowed != tt.allowed {
+				t.Errorf("Handle() allowed = %v, want %v: %v", resp.Allowed, tt.allowed, resp.Result)
+			}
+		})
+	}
+}
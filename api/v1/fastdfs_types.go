@@ -18,6 +18,9 @@ package v1
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -32,7 +35,9 @@ type FastDFSSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// Version specifies expect FastDFS image tag, except 3.6.3
+	// Version specifies expect FastDFS release, except 3.6.3. It is the
+	// image tag of the servers unless pod.image.version overrides it, and
+	// changing it upgrades trackers first, then storage groups one pod at a time
 	//
 	// +required
 	Version string `json:"version"`
@@ -42,30 +47,54 @@ type FastDFSSpec struct {
 	// +optional
 	ServerAuth ServerAuth `json:"serverAuth,omitempty"`
 
-	// Replicas is the expected size of the FastDFS cluster.
-	// The operator will eventually make the size of the running cluster
-	// equal to the expected size.
+	// Replicas is the expected number of FastDFS storage servers in each
+	// storage group which does not specify its own replicas, it is the
+	// target of the scale subresource. Scaling is only accepted while the
+	// cluster has a single storage group following replicas, so that
+	// replicas sizes every storage server the scale selector matches.
+	// The operator will eventually make the size of the running storage
+	// statefulsets equal to the expected size, default 1
 	//
 	// +optional
 	// +kubebuilder:validation:Minimum=0
 	Replicas *int32 `json:"replicas,omitempty"`
 
-	// ParticipantReplicas is the expected size of the FastDFS participants
+	// ParticipantReplicas is the expected size of the FastDFS participants,
+	// default replicas
 	//
 	// +optional
 	// +kubebuilder:validation:Minimum=0
 	ParticipantReplicas *int32 `json:"participantReplicas,omitempty"`
 
-	// Paused specified whether cluster service continue to serve
+	// Paused specified whether cluster service continue to serve,
+	// a paused cluster scales its servers to zero but keeps the
+	// volumes, configuration and services
 	//
 	// +optional
 	Paused bool `json:"paused,omitempty"`
 
-	// Storage specifies storage options
+	// Tracker specifies deploy options of the tracker servers
+	//
+	// +optional
+	Tracker *TrackerOption `json:"tracker,omitempty"`
+
+	// Storage specifies deploy and disk options of the storage servers
 	//
 	// +required
 	Storage *StorageOption `json:"storage,omitempty"`
 
+	// Gateway deploys nginx with fastdfs-nginx-module serving file downloads
+	// at /<group>/M00/... urls, no gateway is deployed when empty
+	//
+	// +optional
+	Gateway *GatewayOption `json:"gateway,omitempty"`
+
+	// NetworkPolicy restricts the pods and networks reaching the cluster,
+	// any client is accepted when empty. Not supported with pod.hostNetwork
+	//
+	// +optional
+	NetworkPolicy *NetworkPolicyOption `json:"networkPolicy,omitempty"`
+
 	// Labels specifies the labels that will be tagged
 	// on all resources created by FastDFSCluster
 	//
@@ -108,9 +137,43 @@ type FastDFSStatus struct {
 	// +optional
 	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
 
+	// ObservedGeneration is the most recent generation the operator applied in full,
+	// the summary conditions refer to it
+	//
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Phase is a brief summary of the cluster lifecycle
+	//
+	// +optional
+	Phase ClusterPhase `json:"phase,omitempty"`
+
+	// Conditions is the latest available observations of the cluster,
+	// known types are Ready, Progressing, Degraded and ConfigApplied
+	//
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Version is the FastDFS release every server of the cluster is running
+	//
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Tracker is the observed state of the tracker servers
+	//
+	// +optional
+	Tracker TrackerStatus `json:"tracker,omitempty"`
+
 	// Replicas is the number of replicas created in the cluster
 	Replicas int32 `json:"replicas"`
 
+	// Selector is the label selector of storage pods, used by the scale subresource
+	//
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
 	// CurrentStatefulSetReplicas is the number of statefulset replicas
 	CurrentStatefulSetReplicas int32 `json:"currentStatefulSetReplicas"`
 
@@ -122,10 +185,180 @@ type FastDFSStatus struct {
 
 	// ReadyReplicas is the number of ready replicas in the cluster that are ready
 	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// UpdatedReplicas is the number of storage replicas running the latest pod template
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+
+	// Groups is the observed state of each storage group
+	//
+	// +optional
+	Groups []StorageGroupStatus `json:"groups,omitempty"`
+
+	// PausedReplicas is the replicas of each statefulset before the cluster
+	// was paused, they are restored when the cluster is unpaused
+	//
+	// +optional
+	PausedReplicas map[string]int32 `json:"pausedReplicas,omitempty"`
+
+	// Endpoints is the addresses clients reach the cluster at
+	//
+	// +optional
+	Endpoints EndpointsStatus `json:"endpoints,omitempty"`
+
+	// ServerAuth is the progress of anti-steal key rotations
+	//
+	// +optional
+	ServerAuth ServerAuthStatus `json:"serverAuth,omitempty"`
+}
+
+type EndpointsStatus struct {
+	// Tracker is the address applications inside the kubernetes cluster reach trackers at
+	//
+	// +optional
+	Tracker string `json:"tracker,omitempty"`
+
+	// TrackerNodePort is the port trackers are exposed at on every node
+	//
+	// +optional
+	TrackerNodePort int32 `json:"trackerNodePort,omitempty"`
+
+	// TrackerExternal is the load balancer addresses trackers are exposed at
+	//
+	// +optional
+	TrackerExternal []string `json:"trackerExternal,omitempty"`
+
+	// Storage is the external address each storage server advertises
+	//
+	// +optional
+	Storage []StorageEndpoint `json:"storage,omitempty"`
+
+	// Gateway is the addresses applications inside the kubernetes cluster download files at
+	//
+	// +optional
+	Gateway []string `json:"gateway,omitempty"`
+
+	// GatewayExternal is the addresses the ingress or the parent gateways of the
+	// route serve downloads at
+	//
+	// +optional
+	GatewayExternal []string `json:"gatewayExternal,omitempty"`
+}
+
+type StorageEndpoint struct {
+	// Pod is the name of the storage pod
+	Pod string `json:"pod"`
+
+	// Group is the storage group of the pod
+	Group string `json:"group"`
+
+	// Address is the external address advertised by the storage server
+	Address string `json:"address"`
+}
+
+type ServerAuthStatus struct {
+	// Phase is Rotating while tokens signed with the previous key are still accepted
+	//
+	// +optional
+	Phase ServerAuthPhase `json:"phase,omitempty"`
+
+	// RotationRequest is the last handled value of the rotate anti-steal key annotation
+	//
+	// +optional
+	RotationRequest string `json:"rotationRequest,omitempty"`
+
+	// RotationStartTime is when the current key replaced the previous one
+	//
+	// +optional
+	RotationStartTime *metav1.Time `json:"rotationStartTime,omitempty"`
+
+	// PreviousKeyExpirationTime is when tokens signed with the previous key stop being accepted
+	//
+	// +optional
+	PreviousKeyExpirationTime *metav1.Time `json:"previousKeyExpirationTime,omitempty"`
+
+	// LastRotationTime is when the last rotation completed
+	//
+	// +optional
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+}
+
+type ServerAuthPhase string
+
+const (
+	ServerAuthPhaseStable   ServerAuthPhase = "Stable"
+	ServerAuthPhaseRotating ServerAuthPhase = "Rotating"
+)
+
+type StorageGroupStatus struct {
+	// Name is the FastDFS group name
+	Name string `json:"name"`
+
+	// Replicas is the number of storage replicas created in the group
+	Replicas int32 `json:"replicas"`
+
+	// CurrentStatefulSetReplicas is the number of replicas of the group statefulset
+	CurrentStatefulSetReplicas int32 `json:"currentStatefulSetReplicas"`
+
+	// ReadyReplicas is the number of ready storage replicas in the group
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// UpdatedReplicas is the number of storage replicas in the group running the latest pod template
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+
+	// Version is the FastDFS release all storage servers of the group are running
+	//
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// StorageIDBase is the storage id of the first server of the group minus one,
+	// assigned once so storage ids stay stable when groups are added or removed
+	//
+	// +optional
+	StorageIDBase int32 `json:"storageIdBase,omitempty"`
+}
+
+type TrackerStatus struct {
+	// Replicas is the number of tracker replicas created in the cluster
+	Replicas int32 `json:"replicas"`
+
+	// ReadyReplicas is the number of ready tracker replicas
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// UpdatedReplicas is the number of tracker replicas running the latest pod template
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+
+	// Version is the FastDFS release all tracker servers are running
+	//
+	// +optional
+	Version string `json:"version,omitempty"`
 }
 
+type ClusterPhase string
+
+const (
+	ClusterPhaseCreating ClusterPhase = "Creating"
+	ClusterPhaseRunning  ClusterPhase = "Running"
+	ClusterPhaseUpdating ClusterPhase = "Updating"
+	ClusterPhaseDegraded ClusterPhase = "Degraded"
+	ClusterPhasePaused   ClusterPhase = "Paused"
+)
+
+const (
+	ConditionReady          = "Ready"
+	ConditionProgressing    = "Progressing"
+	ConditionDegraded       = "Degraded"
+	ConditionConfigApplied  = "ConfigApplied"
+	ConditionPortsAvailable = "PortsAvailable"
+	ConditionRouteAvailable = "RouteAvailable"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+//+kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.version`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // FastDFS is the Schema for the fastdfs API
 type FastDFS struct {
@@ -178,26 +411,41 @@ type PodOption struct {
 	//
 	// +optional
 	Image Image `json:"image,omitempty"`
+
+	// HostNetwork runs trackers, storage servers and sidecar gateways in the host network.
+	// The servers of a role are spread one per node on top of spec.affinity.podAntiAffinity,
+	// and storage servers advertise the node ip to trackers. Not supported with networkPolicy
+	//
+	// +optional
+	HostNetwork bool `json:"hostNetwork,omitempty"`
 }
 
 type Image struct {
-	// container image name
+	// container image name, default fastdfs
 	//
 	// +optional
 	Name string `json:"name,omitempty"`
 
-	//container image version
+	// container image version, overrides the tag derived from spec.version
 	//
 	// +optional
 	Version string `json:"version,omitempty"`
 }
 
 type ServerAuth struct {
+	// Token turns on http.anti_steal.check_token,
 	// disabled auth default false
 	//
 	// +optional
+	// +kubebuilder:validation:Enum="true";"false"
 	Token string `json:"token,omitempty"`
 
+	// TokenRef reads token from a key of a secret in the cluster namespace,
+	// takes precedence over token
+	//
+	// +optional
+	TokenRef *corev1.SecretKeySelector `json:"tokenRef,omitempty"`
+
 	// token TTL (time to live), seconds
 	// default value is 600
 	//
@@ -205,35 +453,92 @@ type ServerAuth struct {
 	TTL int32 `json:"ttl,omitempty"`
 
 	// secret key to generate anti-steal token
-	// this parameter must be set when http.anti_steal.check_token set to true
 	// the length of the secret key should not exceed 128 bytes
+	// Deprecated: the key is readable by anyone able to get the cluster, use secretRef
 	//
 	// +optional
 	Secret string `json:"secret,omitempty"`
 
+	// SecretRef reads the secret key from a key of a secret in the cluster namespace,
+	// a random key is generated into an operator owned secret when neither
+	// secret nor secretRef is set
+	//
+	// +optional
+	SecretRef *corev1.SecretKeySelector `json:"secretRef,omitempty"`
+
+	// RotationGracePeriodSeconds is how long tokens signed with a replaced key
+	// stay valid on the standalone download gateway, default ttl. Other clusters
+	// drop a replaced key at once
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	RotationGracePeriodSeconds *int32 `json:"rotationGracePeriodSeconds,omitempty"`
+
 	// return the content of the file when check token fail
-	// default value is empty (no file sepecified)
+	// default value is empty (no file sepecified), not supported by gateways
 	//
 	// +optional
 	FailFallBack string `json:"failFallBack,omitempty"`
 }
 
 /**
- * GetStatefulSetName is the name of the cluster statefulset
+ * GetRotationGracePeriod is how long the previous anti-steal key is accepted after a rotation
+ *
+ * @return time.Duration
+ */
+func (auth *ServerAuth) GetRotationGracePeriod() time.Duration {
+	seconds := auth.TTL
+	if auth.RotationGracePeriodSeconds != nil {
+		seconds = *auth.RotationGracePeriodSeconds
+	} else if seconds == 0 {
+		seconds = DefaultServerAuthTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+/**
+ * IsTokenChecked tells whether downloads must carry a valid anti-steal token
+ *
+ * @return bool
+ */
+func (auth *ServerAuth) IsTokenChecked() bool {
+	return auth.Token == "true"
+}
+
+/**
+ * GetTrackerStatefulSetName is the name of the tracker statefulset
+ *
+ * @return string
+ */
+func (cluster *FastDFS) GetTrackerStatefulSetName() string {
+	return fmt.Sprintf(TrackerStatefulSetName, cluster.Name)
+}
+
+/**
+ * GetTrackerStatefulSetNamespacedName is the namespaced and name of the tracker statefulset
+ *
+ * @return types.NamespacedName
+ */
+func (cluster *FastDFS) GetTrackerStatefulSetNamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetTrackerStatefulSetName()}
+}
+
+/**
+ * GetStorageStatefulSetName is the name of the statefulset of a storage group
  *
  * @return string
  */
-func (cluster *FastDFS) GetStatefulSetName() string {
-	return fmt.Sprintf(StatefulsetName, cluster.Name)
+func (cluster *FastDFS) GetStorageStatefulSetName(group string) string {
+	return fmt.Sprintf(StorageStatefulSetName, cluster.Name, group)
 }
 
 /**
- * GetStatefulSetNamespacedName is the namespaced and name of the statefulset
+ * GetStorageStatefulSetNamespacedName is the namespaced and name of the statefulset of a storage group
  *
  * @return types.NamespacedName
  */
-func (cluster *FastDFS) GetStatefulSetNamespacedName() types.NamespacedName {
-	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetStatefulSetName()}
+func (cluster *FastDFS) GetStorageStatefulSetNamespacedName(group string) types.NamespacedName {
+	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetStorageStatefulSetName(group)}
 }
 
 /**
@@ -268,13 +573,96 @@ func (cluster *FastDFS) ResourceLabels() map[string]string {
 }
 
 /**
- * HeadlessServiceName is the name of the headless service for the FastDFS cluster
- * - headless service name: <cluster-name>-headless
+ * RoleMatchingLabels is the labels used to select pods of one server role
+ *
+ * @return map[string]string
+ */
+func (cluster *FastDFS) RoleMatchingLabels(role ServerRole) map[string]string {
+	labels := cluster.ResourceMatchingLabels()
+	labels[RoleLabelKey] = string(role)
+	return labels
+}
+
+/**
+ * RoleLabels is the labels that will be tagged on resources of one server role
+ *
+ * @return map[string]string
+ */
+func (cluster *FastDFS) RoleLabels(role ServerRole) map[string]string {
+	labels := cluster.ResourceLabels()
+	labels[RoleLabelKey] = string(role)
+	return labels
+}
+
+/**
+ * GroupMatchingLabels is the labels used to select storage pods of one group
+ *
+ * @return map[string]string
+ */
+func (cluster *FastDFS) GroupMatchingLabels(group string) map[string]string {
+	labels := cluster.RoleMatchingLabels(ServerRoleStorage)
+	labels[GroupLabelKey] = group
+	return labels
+}
+
+/**
+ * GroupLabels is the labels that will be tagged on resources of one storage group
+ *
+ * @return map[string]string
+ */
+func (cluster *FastDFS) GroupLabels(group string) map[string]string {
+	labels := cluster.RoleLabels(ServerRoleStorage)
+	labels[GroupLabelKey] = group
+	return labels
+}
+
+/**
+ * GatewayMatchingLabels is the labels selecting gateways, of a group when group is set
+ *
+ * @return map[string]string
+ */
+func (cluster *FastDFS) GatewayMatchingLabels(group string) map[string]string {
+	labels := cluster.RoleMatchingLabels(ServerRoleGateway)
+	if group != "" {
+		labels[GroupLabelKey] = group
+	}
+	return labels
+}
+
+/**
+ * GatewayLabels is the labels tagged on gateway resources, of a group when group is set
+ *
+ * @return map[string]string
+ */
+func (cluster *FastDFS) GatewayLabels(group string) map[string]string {
+	labels := cluster.RoleLabels(ServerRoleGateway)
+	if group != "" {
+		labels[GroupLabelKey] = group
+	}
+	return labels
+}
+
+/**
+ * HeadlessServiceName is the name of the headless service for servers of a role
+ * - headless service name: <cluster-name>-<role>-headless
  * statefulset need a unique service tag to expose net
  * @return string
  */
-func (cluster *FastDFS) GetHeadlessServiceName() string {
-	return fmt.Sprintf(HeadlessServiceName, cluster.Name)
+func (cluster *FastDFS) GetHeadlessServiceName(role ServerRole) string {
+	return fmt.Sprintf(HeadlessServiceName, cluster.Name, role)
+}
+
+func (cluster *FastDFS) GetHeadlessServiceNamespacedName(role ServerRole) types.NamespacedName {
+	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetHeadlessServiceName(role)}
+}
+
+// GetTrackerServiceName is the client service of trackers
+func (cluster *FastDFS) GetTrackerServiceName() string {
+	return fmt.Sprintf(TrackerServiceName, cluster.Name)
+}
+
+func (cluster *FastDFS) GetTrackerServiceNamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetTrackerServiceName()}
 }
 
 func (cluster *FastDFS) GetConfigMapName() string {
@@ -285,71 +673,1029 @@ func (cluster *FastDFS) GetConfigMapNamespacedName() types.NamespacedName {
 	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetConfigMapName()}
 }
 
-func (cluster *FastDFS) GetPersistentVolumeClaimName(ordinal int) string {
-	return fmt.Sprintf("%s-%s", PvcName, cluster.GetPodName(int32(ordinal)))
+// GetHTTPConfigSecretName is the secret holding the rendered http.conf
+func (cluster *FastDFS) GetHTTPConfigSecretName() string {
+	return fmt.Sprintf(HTTPConfigSecretName, cluster.Name)
 }
 
-func (cluster *FastDFS) GetPersistentVolumeClaimNamespacedName(ordinal int) types.NamespacedName {
-	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetPersistentVolumeClaimName(ordinal)}
+func (cluster *FastDFS) GetHTTPConfigSecretNamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetHTTPConfigSecretName()}
 }
 
-func (cluster *FastDFS) GetPodName(ordinal int32) string {
-	return fmt.Sprintf("%s-%d", cluster.GetStatefulSetName(), ordinal)
+// GetAntiStealSecretName is the operator owned secret holding the generated anti-steal key
+func (cluster *FastDFS) GetAntiStealSecretName() string {
+	return fmt.Sprintf(AntiStealSecretName, cluster.Name)
 }
 
-func (cluster *FastDFS) IgnoreSchedulePolicy() bool {
-	if cluster.Annotations != nil && cluster.Annotations[ScheduleTypeAnnotation] == ScheduleTypeAnnotationValueIgnore {
-		return true
-	}
-	return false
+func (cluster *FastDFS) GetAntiStealSecretNamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetAntiStealSecretName()}
 }
 
-type VolumeReclaimPolicy string
+// GetStorageConfigFileName is the configmap key holding storage.conf of the group
+func (cluster *FastDFS) GetStorageConfigFileName(group string) string {
+	return fmt.Sprintf(GroupStorageConfigFile, group)
+}
 
-const (
-	VolumeReclaimPolicyRetain VolumeReclaimPolicy = "Retain"
-	VolumeReclaimPolicyDelete VolumeReclaimPolicy = "Delete"
-)
+func (cluster *FastDFS) GetPersistentVolumeClaimName(group string, ordinal int) string {
+	return fmt.Sprintf("%s-%s", PvcName, cluster.GetPodName(group, int32(ordinal)))
+}
 
-type StorageOption struct {
-	// DiskSize specifies the storage size of pod
-	// unit Gi
-	//
-	// +required
-	// +kubebuilder:validation:Minimum=1
-	DiskSize int32 `json:"diskSize,omitempty"`
+func (cluster *FastDFS) GetPersistentVolumeClaimNamespacedName(group string, ordinal int) types.NamespacedName {
+	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetPersistentVolumeClaimName(group, ordinal)}
+}
 
-	// Unit specifies the unit of DiskSize, default Gi
-	//
-	// +optional
-	// +kubebuilder:validation:Enum=Mi;Gi
-	Unit string `json:"unit,omitempty"`
+func (cluster *FastDFS) GetPodName(group string, ordinal int32) string {
+	return fmt.Sprintf("%s-%d", cluster.GetStorageStatefulSetName(group), ordinal)
+}
 
-	// StorageClass specifies storageclass used by pvc
-	//
-	// +optional
-	StorageClass *string `json:"storageClass,omitempty"`
+// GetExternalServiceName is the external service of a storage pod
+func (cluster *FastDFS) GetExternalServiceName(group string, ordinal int32) string {
+	return fmt.Sprintf(ExternalServiceName, cluster.GetPodName(group, ordinal))
+}
 
-	// VolumeReclaimPolicy is a zookeeper operator configuration. If it's set to Delete,
-	// the corresponding PVCs will be deleted by the operator when zookeeper cluster is deleted.
-	// The default value is Retain.
-	//
-	// +optional
-	// +kubebuilder:validation:Enum="Delete";"Retain"
-	VolumeReclaimPolicy VolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+func (cluster *FastDFS) GetExternalServiceNamespacedName(group string, ordinal int32) types.NamespacedName {
+	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetExternalServiceName(group, ordinal)}
+}
+
+/**
+ * GetStorageEndpoint is the external address advertised by a storage pod, empty
+ * until its service is reachable
+ *
+ * @return string
+ */
+func (cluster *FastDFS) GetStorageEndpoint(pod string) string {
+	for _, endpoint := range cluster.Status.Endpoints.Storage {
+		if endpoint.Pod == pod {
+			return endpoint.Address
+		}
+	}
+	return ""
+}
+
+func (cluster *FastDFS) GetTrackerPodName(ordinal int32) string {
+	return fmt.Sprintf("%s-%d", cluster.GetTrackerStatefulSetName(), ordinal)
+}
+
+/**
+ * GetTrackerServer is the address storage servers use to reach the first tracker,
+ * resolved through the headless service
+ *
+ * @return string
+ */
+func (cluster *FastDFS) GetTrackerServer() string {
+	return cluster.getTrackerServer(0)
+}
+
+/**
+ * GetTrackerServers is the address of every expected tracker, resolved
+ * through the headless service
+ *
+ * @return []string
+ */
+func (cluster *FastDFS) GetTrackerServers() []string {
+	servers := []string{}
+	for i := int32(0); i < *cluster.GetTrackerReplicas(); i++ {
+		servers = append(servers, cluster.getTrackerServer(i))
+	}
+	return servers
+}
+
+func (cluster *FastDFS) getTrackerServer(ordinal int32) string {
+	return fmt.Sprintf("%s.%s.%s.svc:%d", cluster.GetTrackerPodName(ordinal), cluster.GetHeadlessServiceName(ServerRoleTracker),
+		cluster.Namespace, cluster.GetTrackerPort())
+}
+
+/**
+ * GetGatewayName is the name of the standalone gateway deployment and service
+ *
+ * @return string
+ */
+func (cluster *FastDFS) GetGatewayName() string {
+	return fmt.Sprintf(GatewayName, cluster.Name)
+}
+
+func (cluster *FastDFS) GetGatewayNamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetGatewayName()}
+}
+
+/**
+ * GetGroupGatewayName is the name of the service of the sidecar gateways of a group
+ *
+ * @return string
+ */
+func (cluster *FastDFS) GetGroupGatewayName(group string) string {
+	return fmt.Sprintf(GroupGatewayName, cluster.Name, group)
+}
+
+func (cluster *FastDFS) GetGroupGatewayNamespacedName(group string) types.NamespacedName {
+	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetGroupGatewayName(group)}
+}
+
+/**
+ * GetModFastDFSConfigFileName is the configmap key of mod_fastdfs.conf of the sidecar gateways of a group
+ *
+ * @return string
+ */
+func (cluster *FastDFS) GetModFastDFSConfigFileName(group string) string {
+	return fmt.Sprintf(GroupModFastDFSConfigFile, group)
+}
+
+/**
+ * GetNetworkPolicyName is the name of the network policy guarding the servers of a role,
+ * storage servers are guarded per group
+ *
+ * @return string
+ */
+func (cluster *FastDFS) GetNetworkPolicyName(role ServerRole, group string) string {
+	if group != "" {
+		return fmt.Sprintf(GroupNetworkPolicyName, cluster.Name, role, group)
+	}
+	return fmt.Sprintf(NetworkPolicyName, cluster.Name, role)
+}
+
+func (cluster *FastDFS) GetNetworkPolicyNamespacedName(role ServerRole, group string) types.NamespacedName {
+	return types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.GetNetworkPolicyName(role, group)}
+}
+
+/**
+ * GetGatewayTLSSecretName is the secret holding the certificate of the gateway hostnames,
+ * empty when downloads are not served over TLS
+ *
+ * @return string
+ */
+func (cluster *FastDFS) GetGatewayTLSSecretName() string {
+	if cluster.Spec.Gateway == nil || cluster.Spec.Gateway.Ingress == nil {
+		return ""
+	}
+	ingress := cluster.Spec.Gateway.Ingress
+	if ingress.TLSSecretName == "" && ingress.Issuer != nil {
+		return fmt.Sprintf(GatewayTLSSecretName, cluster.Name)
+	}
+	return ingress.TLSSecretName
+}
+
+/**
+ * GetIngressKind is the kind of the gateway route, empty when downloads are not routed
+ *
+ * @return IngressKind
+ */
+func (cluster *FastDFS) GetIngressKind() IngressKind {
+	if cluster.Spec.Gateway == nil || cluster.Spec.Gateway.Ingress == nil {
+		return ""
+	}
+	if cluster.Spec.Gateway.Ingress.Kind == "" {
+		return IngressKindIngress
+	}
+	return cluster.Spec.Gateway.Ingress.Kind
+}
+
+/**
+ * GetIngressGroups is the storage groups routed by the gateway route
+ *
+ * @return []string
+ */
+func (cluster *FastDFS) GetIngressGroups() []string {
+	if cluster.Spec.Gateway != nil && cluster.Spec.Gateway.Ingress != nil && len(cluster.Spec.Gateway.Ingress.Groups) != 0 {
+		return cluster.Spec.Gateway.Ingress.Groups
+	}
+	groups := []string{}
+	for _, group := range cluster.GetStorageGroups() {
+		groups = append(groups, group.Name)
+	}
+	return groups
+}
+
+/**
+ * GetGatewayMode is the mode of the gateway, empty when no gateway is deployed
+ *
+ * @return GatewayMode
+ */
+func (cluster *FastDFS) GetGatewayMode() GatewayMode {
+	if cluster.Spec.Gateway == nil {
+		return ""
+	}
+	if cluster.Spec.Gateway.Mode == "" {
+		return GatewayModeStandalone
+	}
+	return cluster.Spec.Gateway.Mode
+}
+
+func (cluster *FastDFS) GetGatewayReplicas() *int32 {
+	replicas := int32(DefaultGatewayReplicas)
+	if cluster.Spec.Gateway != nil && cluster.Spec.Gateway.Replicas != nil {
+		replicas = *cluster.Spec.Gateway.Replicas
+	}
+	return &replicas
+}
+
+/**
+ * GetGatewayPort is the port gateways serve downloads on
+ *
+ * @return int
+ */
+func (cluster *FastDFS) GetGatewayPort() int {
+	if cluster.Spec.Gateway != nil && cluster.Spec.Gateway.Port != nil {
+		return int(*cluster.Spec.Gateway.Port)
+	}
+	return DefaultGatewayPort
+}
+
+/**
+ * GetGatewayImage is the gateway image, the server image of the release unless overridden
+ *
+ * @return string
+ */
+func (cluster *FastDFS) GetGatewayImage(version string) string {
+	if cluster.Spec.Gateway != nil && cluster.Spec.Gateway.Image != "" {
+		return cluster.Spec.Gateway.Image
+	}
+	return cluster.GetImage(version)
+}
+
+/**
+ * GetTrackerPort is the port trackers serve on
+ *
+ * @return int
+ */
+func (cluster *FastDFS) GetTrackerPort() int {
+	if cluster.Spec.Tracker != nil && cluster.Spec.Tracker.Port != nil {
+		return int(*cluster.Spec.Tracker.Port)
+	}
+	return DefaultTrackerPort
+}
+
+/**
+ * GetStoragePort is the port storage servers serve on
+ *
+ * @return int
+ */
+func (cluster *FastDFS) GetStoragePort() int {
+	if cluster.Spec.Storage != nil && cluster.Spec.Storage.Port != nil {
+		return int(*cluster.Spec.Storage.Port)
+	}
+	return DefaultStoragePort
+}
+
+/**
+ * GetStorageHTTPPort is the port storage servers serve file downloads on
+ *
+ * @return int
+ */
+func (cluster *FastDFS) GetStorageHTTPPort() int {
+	if cluster.Spec.Storage != nil && cluster.Spec.Storage.HTTPPort != nil {
+		return int(*cluster.Spec.Storage.HTTPPort)
+	}
+	return DefaultStorageHTTPPort
+}
+
+/**
+ * GetMajorVersion is the major number of spec.version such as 6 of v6.12, 0 when unknown
+ *
+ * @return int
+ */
+func (cluster *FastDFS) GetMajorVersion() int {
+	major, _ := strconv.Atoi(strings.Split(strings.TrimPrefix(cluster.Spec.Version, "v"), ".")[0])
+	return major
+}
+
+/**
+ * GetImage is the server image of a FastDFS release, the tag follows
+ * the release unless pod.image.version overrides it
+ *
+ * @return string
+ */
+func (cluster *FastDFS) GetImage(version string) string {
+	name, tag := DefaultImageName, version
+	repository := ""
+	if pod := cluster.Spec.Pod; pod != nil {
+		repository = pod.ImagePullRepository
+		if pod.Image.Name != "" {
+			name = pod.Image.Name
+		}
+		if pod.Image.Version != "" {
+			tag = pod.Image.Version
+		}
+	}
+	if repository != "" {
+		name = repository + "/" + name
+	}
+	return name + ":" + tag
+}
+
+func (cluster *FastDFS) GetTrackerReplicas() *int32 {
+	replicas := int32(DefaultTrackerReplicas)
+	if cluster.Spec.Tracker != nil && cluster.Spec.Tracker.Replicas != nil {
+		replicas = *cluster.Spec.Tracker.Replicas
+	}
+	return &replicas
+}
+
+/**
+ * IsHostNetwork tells whether the servers run in the host network
+ *
+ * @return bool
+ */
+func (cluster *FastDFS) IsHostNetwork() bool {
+	return cluster.Spec.Pod != nil && cluster.Spec.Pod.HostNetwork
+}
+
+func (cluster *FastDFS) IgnoreSchedulePolicy() bool {
+	if cluster.Annotations != nil && cluster.Annotations[ScheduleTypeAnnotation] == ScheduleTypeAnnotationValueIgnore {
+		return true
+	}
+	return false
+}
+
+type NetworkPolicyOption struct {
+	// Clients is the pods allowed to reach trackers, storage servers and gateways,
+	// FastDFS clients upload and download files at storage servers directly
+	//
+	// +optional
+	Clients []NetworkPolicyClient `json:"clients,omitempty"`
+
+	// IPBlocks is the CIDRs outside the kubernetes cluster allowed to reach the cluster,
+	// e.g. clients of storage.externalService
+	//
+	// +optional
+	IPBlocks []string `json:"ipBlocks,omitempty"`
+
+	// PodCIDRs is the pod network of the kubernetes cluster. FastDFS only filters
+	// clients by address, allow_hosts of trackers and storage servers is rendered from
+	// podCIDRs and ipBlocks while network policies narrow pods down to the clients.
+	// allow_hosts is left open when empty
+	//
+	// +optional
+	PodCIDRs []string `json:"podCIDRs,omitempty"`
+}
+
+type NetworkPolicyClient struct {
+	// NamespaceSelector selects the namespaces of the clients, the namespace
+	// of the cluster when empty
+	//
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// PodSelector selects the client pods, all pods of the namespaces when empty
+	//
+	// +optional
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+}
+
+type ServerRole string
+
+const (
+	ServerRoleTracker ServerRole = "tracker"
+	ServerRoleStorage ServerRole = "storage"
+	ServerRoleGateway ServerRole = "gateway"
+)
+
+type GatewayMode string
+
+const (
+	// GatewayModeStandalone runs the gateway as its own deployment, files are
+	// proxied from the storage servers holding them
+	GatewayModeStandalone GatewayMode = "Standalone"
+	// GatewayModeSidecar runs the gateway next to every storage server, files
+	// are read from the local volume when the storage server holds them
+	GatewayModeSidecar GatewayMode = "Sidecar"
+)
+
+type GatewayOption struct {
+	// Mode is either Standalone or Sidecar, default Standalone
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Standalone;Sidecar
+	Mode GatewayMode `json:"mode,omitempty"`
+
+	// Replicas is the expected number of standalone gateway pods, default 1.
+	// Sidecars always follow the storage servers
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Resources specifies the resource needed per gateway container,
+	// fall back to pod resources when empty
+	//
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Image is the nginx image built with fastdfs-nginx-module and the FastDFS tools,
+	// default the server image
+	//
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Port is the port nginx serves downloads on, default 80
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port *int32 `json:"port,omitempty"`
+
+	// Service customizes the service applications download files through,
+	// sidecars get a service per storage group
+	//
+	// +optional
+	Service *ServiceOption `json:"service,omitempty"`
+
+	// Ingress routes downloads from outside the kubernetes cluster to the gateways
+	//
+	// +optional
+	Ingress *GatewayIngressOption `json:"ingress,omitempty"`
+}
+
+type IngressKind string
+
+const (
+	IngressKindIngress   IngressKind = "Ingress"
+	IngressKindHTTPRoute IngressKind = "HTTPRoute"
+)
+
+type GatewayIngressOption struct {
+	// Kind is Ingress for a networking.k8s.io Ingress, or HTTPRoute for a
+	// Gateway API HTTPRoute attached to the parent gateways, default Ingress
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Ingress;HTTPRoute
+	Kind IngressKind `json:"kind,omitempty"`
+
+	// ClassName is the ingress class of the Ingress
+	//
+	// +optional
+	ClassName *string `json:"className,omitempty"`
+
+	// ParentRefs is the gateways the HTTPRoute attaches to, TLS of an HTTPRoute
+	// terminates at the listeners of its parent gateways
+	//
+	// +optional
+	ParentRefs []ParentReference `json:"parentRefs,omitempty"`
+
+	// Hostnames is the hosts downloads are served at, any host when empty
+	//
+	// +optional
+	Hostnames []string `json:"hostnames,omitempty"`
+
+	// TLSSecretName is the secret holding the certificate of the hostnames, default
+	// <cluster>-gateway-tls when a cert-manager issuer is set
+	//
+	// +optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+
+	// Issuer is the cert-manager issuer signing the certificate of the hostnames
+	//
+	// +optional
+	Issuer *IssuerReference `json:"issuer,omitempty"`
+
+	// Annotations is added to the Ingress or HTTPRoute
+	//
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Groups is the storage groups routed, each at /<group>/, default all groups
+	//
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+}
+
+type ParentReference struct {
+	// Name is the name of the gateway
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the gateway, default the namespace of the cluster
+	//
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName is the listener of the gateway the route attaches to
+	//
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+type IssuerReference struct {
+	// Name is the name of the issuer
+	Name string `json:"name"`
+
+	// Kind is Issuer or ClusterIssuer, default Issuer
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	Kind string `json:"kind,omitempty"`
+}
+
+type TrackerOption struct {
+	// Replicas is the expected number of FastDFS tracker servers, default 1
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Resources specifies the resource needed per tracker pod,
+	// fall back to pod resources when empty
+	//
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Command overrides the entrypoint of the tracker container
+	// default /usr/bin/start.sh tracker
+	//
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Port is the port trackers serve on, default 22122
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port *int32 `json:"port,omitempty"`
+
+	// Config tunes the tracker.conf rendered by the operator,
+	// unset parameters keep the FastDFS defaults
+	//
+	// +optional
+	Config *TrackerConfig `json:"config,omitempty"`
+
+	// ConfigOverrides sets raw tracker.conf parameters on top of config,
+	// parameters managed by the operator such as port and base_path are rejected,
+	// allow_hosts follows spec.networkPolicy
+	//
+	// +optional
+	ConfigOverrides map[string]string `json:"configOverrides,omitempty"`
+
+	// Service customizes the client service applications reach trackers through
+	//
+	// +optional
+	Service *ServiceOption `json:"service,omitempty"`
+}
+
+type ExternalServiceOption struct {
+	// Type is the type of the services, only LoadBalancer is supported since FastDFS
+	// clients connect to the storage port of the advertised address, which a node port
+	// never is
+	//
+	// +optional
+	// +kubebuilder:default=LoadBalancer
+	// +kubebuilder:validation:Enum=LoadBalancer
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Annotations is added to the services, e.g. to configure the load balancer
+	//
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// LoadBalancerSourceRanges restricts the clients of LoadBalancer services
+	//
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// ExternalTrafficPolicy routes external traffic to node local or cluster wide endpoints,
+	// default Local so the load balancer only sends traffic to the node running the pod
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Cluster;Local
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
+}
+
+type ServiceOption struct {
+	// Type is the type of the service, default ClusterIP
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Annotations is added to the service, e.g. to configure the load balancer
+	//
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// LoadBalancerSourceRanges restricts the clients of a LoadBalancer service
+	//
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// ExternalTrafficPolicy routes external traffic to node local or cluster wide endpoints,
+	// only applies to NodePort and LoadBalancer services
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Cluster;Local
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
+
+	// NodePorts fixes the node port of service ports by port name, e.g. tracker,
+	// node ports are allocated by kubernetes when empty
+	//
+	// +optional
+	NodePorts map[string]int32 `json:"nodePorts,omitempty"`
+}
+
+// TrackerConfig is the tunable parameters of tracker.conf, a parameter is named
+// after the snake case of its json name, e.g. storeLookup is store_lookup
+type TrackerConfig struct {
+	// StoreLookup is the method selecting the group to upload files to,
+	// 0: round robin, 1: specify group, 2: load balance
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=0;1;2
+	StoreLookup *int32 `json:"storeLookup,omitempty"`
+
+	// StoreGroup is the group to upload files to when storeLookup is 1
+	//
+	// +optional
+	StoreGroup string `json:"storeGroup,omitempty"`
+
+	// StoreServer is the method selecting the storage server to upload files to,
+	// 0: round robin, 1: first server order by ip, 2: first server order by priority
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=0;1;2
+	StoreServer *int32 `json:"storeServer,omitempty"`
+
+	// StorePath is the method selecting the store path to upload files to,
+	// 0: round robin, 2: load balance
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=0;2
+	StorePath *int32 `json:"storePath,omitempty"`
+
+	// DownloadServer is the method selecting the storage server to download files from,
+	// 0: round robin, 1: the source storage server
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=0;1
+	DownloadServer *int32 `json:"downloadServer,omitempty"`
+
+	// ReservedStorageSpace is the space reserved on storage servers, e.g. 20% or 10G
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?%|[0-9]+[GMK]?)$`
+	ReservedStorageSpace string `json:"reservedStorageSpace,omitempty"`
+
+	// MaxConnections is the max concurrent connections the tracker serves
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxConnections *int32 `json:"maxConnections,omitempty"`
+
+	// WorkThreads is the number of threads handling network io
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	WorkThreads *int32 `json:"workThreads,omitempty"`
+
+	// SyncLogBuffInterval is the interval in seconds to sync log buffer to disk
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	SyncLogBuffInterval *int32 `json:"syncLogBuffInterval,omitempty"`
+
+	// CheckActiveInterval is the interval in seconds after which a silent
+	// storage server is considered offline
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	CheckActiveInterval *int32 `json:"checkActiveInterval,omitempty"`
+
+	// UseStorageId identifies storage servers by the ids of the storage_ids.conf generated
+	// by the operator instead of their ip address, so rescheduled pods keep their identity.
+	// Default true, always enabled when storage.externalService is set
+	//
+	// +optional
+	UseStorageId *bool `json:"useStorageId,omitempty"`
+
+	// UseTrunkFile merges small files into trunk files
+	//
+	// +optional
+	UseTrunkFile *bool `json:"useTrunkFile,omitempty"`
+
+	// SlotMinSize is the min size of a trunk slot, e.g. 256
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+[GMK]?B?$`
+	SlotMinSize string `json:"slotMinSize,omitempty"`
+
+	// SlotMaxSize is the max size of a file stored in trunk files, e.g. 16MB
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+[GMK]?B?$`
+	SlotMaxSize string `json:"slotMaxSize,omitempty"`
+
+	// TrunkFileSize is the size of a trunk file, e.g. 64MB
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+[GMK]?B?$`
+	TrunkFileSize string `json:"trunkFileSize,omitempty"`
+
+	// TrunkCreateFileAdvance creates trunk files ahead of time
+	//
+	// +optional
+	TrunkCreateFileAdvance *bool `json:"trunkCreateFileAdvance,omitempty"`
+
+	// TrunkCreateFileTimeBase is the time of day to create trunk files, e.g. 02:00
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	TrunkCreateFileTimeBase string `json:"trunkCreateFileTimeBase,omitempty"`
+
+	// TrunkCreateFileInterval is the interval in seconds to create trunk files
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TrunkCreateFileInterval *int32 `json:"trunkCreateFileInterval,omitempty"`
+
+	// TrunkCreateFileSpaceThreshold is the free trunk space to keep when
+	// creating trunk files ahead of time, e.g. 20G
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+[GMK]?B?$`
+	TrunkCreateFileSpaceThreshold string `json:"trunkCreateFileSpaceThreshold,omitempty"`
+}
+
+type VolumeReclaimPolicy string
+
+const (
+	VolumeReclaimPolicyRetain VolumeReclaimPolicy = "Retain"
+	VolumeReclaimPolicyDelete VolumeReclaimPolicy = "Delete"
+)
+
+type StorageOption struct {
+	// DiskSize specifies the storage size of pod
+	// unit Gi
+	//
+	// +required
+	// +kubebuilder:validation:Minimum=1
+	DiskSize int32 `json:"diskSize,omitempty"`
+
+	// Unit specifies the unit of DiskSize, default Gi
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Mi;Gi
+	Unit string `json:"unit,omitempty"`
+
+	// StorageClass specifies storageclass used by pvc
+	//
+	// +optional
+	StorageClass *string `json:"storageClass,omitempty"`
+
+	// VolumeReclaimPolicy is a zookeeper operator configuration. If it's set to Delete,
+	// the corresponding PVCs will be deleted by the operator when zookeeper cluster is deleted.
+	// The default value is Retain.
+	//
+	// +optional
+	// +kubebuilder:validation:Enum="Delete";"Retain"
+	VolumeReclaimPolicy VolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+
+	// Resources specifies the resource needed per storage pod,
+	// fall back to pod resources when empty
+	//
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Command overrides the entrypoint of the storage container
+	// default /usr/bin/start.sh storage
+	//
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Port is the port storage servers serve on, default 23000
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port *int32 `json:"port,omitempty"`
+
+	// HTTPPort is the port storage servers serve file downloads on, default 8888
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	HTTPPort *int32 `json:"httpPort,omitempty"`
+
+	// Config tunes the storage.conf rendered by the operator for every group,
+	// unset parameters keep the FastDFS defaults
+	//
+	// +optional
+	Config *StorageConfig `json:"config,omitempty"`
+
+	// ConfigOverrides sets raw storage.conf parameters of every group on top of config,
+	// parameters managed by the operator such as tracker_server and base_path are rejected,
+	// allow_hosts follows spec.networkPolicy and bind_addr spec.pod.hostNetwork
+	//
+	// +optional
+	ConfigOverrides map[string]string `json:"configOverrides,omitempty"`
+
+	// ExternalService creates a service per storage pod so clients outside the kubernetes
+	// cluster reach storage servers directly, every storage server advertises the
+	// address of its service next to its pod address, requires FastDFS 6 or later
+	//
+	// +optional
+	ExternalService *ExternalServiceOption `json:"externalService,omitempty"`
+
+	// Groups specifies the storage groups of the cluster, each group is
+	// deployed as its own statefulset. A single group named group1 is
+	// deployed when empty. Groups can be appended but never removed
+	//
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Groups []StorageGroup `json:"groups,omitempty"`
+}
+
+// StorageConfig is the tunable parameters of storage.conf, a parameter is named
+// after the snake case of its json name, e.g. syncWaitMsec is sync_wait_msec
+type StorageConfig struct {
+	// MaxConnections is the max concurrent connections the storage server serves
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxConnections *int32 `json:"maxConnections,omitempty"`
+
+	// WorkThreads is the number of threads handling network io
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	WorkThreads *int32 `json:"workThreads,omitempty"`
+
+	// BuffSize is the size of the buffer receiving and sending data, e.g. 256KB
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+[GMK]?B?$`
+	BuffSize string `json:"buffSize,omitempty"`
+
+	// DiskRwSeparated separates the threads reading and writing disks
+	//
+	// +optional
+	DiskRwSeparated *bool `json:"diskRwSeparated,omitempty"`
+
+	// DiskReaderThreads is the number of threads reading per store path
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	DiskReaderThreads *int32 `json:"diskReaderThreads,omitempty"`
+
+	// DiskWriterThreads is the number of threads writing per store path
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	DiskWriterThreads *int32 `json:"diskWriterThreads,omitempty"`
+
+	// SyncWaitMsec is the milliseconds to wait when there is no file to sync
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	SyncWaitMsec *int32 `json:"syncWaitMsec,omitempty"`
+
+	// SyncInterval is the milliseconds to sleep after a file is synced, 0 syncs continuously
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	SyncInterval *int32 `json:"syncInterval,omitempty"`
+
+	// SyncStartTime is the time of day files start to sync, e.g. 00:00
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	SyncStartTime string `json:"syncStartTime,omitempty"`
+
+	// SyncEndTime is the time of day files stop to sync, e.g. 23:59
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	SyncEndTime string `json:"syncEndTime,omitempty"`
+
+	// WriteMarkFileFreq is the number of synced files after which the mark file is written
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	WriteMarkFileFreq *int32 `json:"writeMarkFileFreq,omitempty"`
+
+	// SyncLogBuffInterval is the interval in seconds to sync log buffer to disk
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	SyncLogBuffInterval *int32 `json:"syncLogBuffInterval,omitempty"`
+
+	// SyncBinlogBuffInterval is the interval in seconds to sync binlog buffer to disk
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	SyncBinlogBuffInterval *int32 `json:"syncBinlogBuffInterval,omitempty"`
+
+	// SyncStatFileInterval is the interval in seconds to sync stat info to disk
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	SyncStatFileInterval *int32 `json:"syncStatFileInterval,omitempty"`
+
+	// FsyncAfterWrittenBytes calls fsync after the bytes are written, 0 never calls fsync
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	FsyncAfterWrittenBytes *int32 `json:"fsyncAfterWrittenBytes,omitempty"`
+}
+
+type StorageGroup struct {
+	// Name specifies the FastDFS group_name of the group
+	//
+	// +required
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]{1,16}$`
+	Name string `json:"name"`
+
+	// Replicas is the expected number of storage servers in the group,
+	// default spec.replicas
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// DiskSize specifies the storage size of pod in the group,
+	// default storage.diskSize
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	DiskSize int32 `json:"diskSize,omitempty"`
+
+	// StorageClass specifies storageclass used by pvc of the group,
+	// default storage.storageClass
+	//
+	// +optional
+	StorageClass *string `json:"storageClass,omitempty"`
+}
+
+/**
+ * GetStorageGroups is the storage groups with cluster level options applied,
+ * a cluster without groups runs a single group named group1
+ *
+ * @return []StorageGroup
+ */
+func (cluster *FastDFS) GetStorageGroups() []StorageGroup {
+	groups := []StorageGroup{{Name: DefaultStorageGroupName}}
+	if cluster.Spec.Storage != nil && len(cluster.Spec.Storage.Groups) != 0 {
+		groups = make([]StorageGroup, 0, len(cluster.Spec.Storage.Groups))
+		for i := range cluster.Spec.Storage.Groups {
+			groups = append(groups, *cluster.Spec.Storage.Groups[i].DeepCopy())
+		}
+	}
+
+	for i := range groups {
+		if groups[i].Replicas == nil && cluster.Spec.Replicas != nil {
+			replicas := *cluster.Spec.Replicas
+			groups[i].Replicas = &replicas
+		}
+		if cluster.Spec.Storage == nil {
+			continue
+		}
+		if groups[i].DiskSize == 0 {
+			groups[i].DiskSize = cluster.Spec.Storage.DiskSize
+		}
+		if groups[i].StorageClass == nil && cluster.Spec.Storage.StorageClass != nil {
+			storageClass := *cluster.Spec.Storage.StorageClass
+			groups[i].StorageClass = &storageClass
+		}
+	}
+	return groups
+}
+
+/**
+ * IsScalable tells whether spec.replicas sizes every storage server, which
+ * holds when the only storage group does not specify its own replicas
+ *
+ * @return bool
+ */
+func (cluster *FastDFS) IsScalable() bool {
+	if cluster.Spec.Storage == nil || len(cluster.Spec.Storage.Groups) == 0 {
+		return true
+	}
+	return len(cluster.Spec.Storage.Groups) == 1 && cluster.Spec.Storage.Groups[0].Replicas == nil
+}
+
+/**
+ * GetStorageGroupStatus is the status of a storage group, an empty status
+ * is appended when the group was never observed
+ *
+ * @return *StorageGroupStatus
+ */
+func (cluster *FastDFS) GetStorageGroupStatus(group string) *StorageGroupStatus {
+	for i := range cluster.Status.Groups {
+		if cluster.Status.Groups[i].Name == group {
+			return &cluster.Status.Groups[i]
+		}
+	}
+	cluster.Status.Groups = append(cluster.Status.Groups, StorageGroupStatus{Name: group})
+	return &cluster.Status.Groups[len(cluster.Status.Groups)-1]
 }
 
-func (cluster *FastDFS) NextReplicas() *int32 {
-	nextReplicas := cluster.Status.CurrentStatefulSetReplicas
+func (cluster *FastDFS) NextReplicas(group *StorageGroup) *int32 {
+	status := cluster.GetStorageGroupStatus(group.Name)
+	nextReplicas := status.CurrentStatefulSetReplicas
 
-	if cluster.Status.CurrentStatefulSetReplicas < *cluster.Spec.Replicas {
+	if status.CurrentStatefulSetReplicas < *group.Replicas {
 		// when scale up, make sure previous replica be ready
-		if cluster.Status.ReadyReplicas == cluster.Status.CurrentStatefulSetReplicas {
-			nextReplicas = cluster.Status.ReadyReplicas + 1
+		if status.ReadyReplicas == status.CurrentStatefulSetReplicas {
+			nextReplicas = status.ReadyReplicas + 1
 		}
-	} else if cluster.Status.CurrentStatefulSetReplicas > *cluster.Spec.Replicas {
+	} else if status.CurrentStatefulSetReplicas > *group.Replicas {
 		// when scale down, scale immediately
-		nextReplicas = *cluster.Spec.Replicas
+		nextReplicas = *group.Replicas
 	}
 
 	return &nextReplicas
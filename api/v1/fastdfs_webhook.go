@@ -0,0 +1,369 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var fastdfslog = logf.Log.WithName("fastdfs-resource")
+
+// versionPattern matches FastDFS release tags such as 6.12 or v6.12.1
+var versionPattern = regexp.MustCompile(`^v?\d+\.\d+(\.\d+)?$`)
+
+func (cluster *FastDFS) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(scaleWebhookPath,
+		&webhook.Admission{Handler: &scaleValidator{client: mgr.GetClient()}})
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(cluster).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-fastdfs-beordie-cn-v1-fastdfs,mutating=true,failurePolicy=fail,sideEffects=None,groups=fastdfs.beordie.cn,resources=fastdfses,verbs=create;update,versions=v1,name=mfastdfs.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &FastDFS{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (cluster *FastDFS) Default() {
+	fastdfslog.Info("default", "name", cluster.Name)
+
+	spec := &cluster.Spec
+	if spec.Replicas == nil {
+		replicas := int32(DefaultReplicas)
+		spec.Replicas = &replicas
+	}
+	if spec.ParticipantReplicas == nil {
+		// every storage server participates unless told otherwise
+		participantReplicas := *spec.Replicas
+		spec.ParticipantReplicas = &participantReplicas
+	}
+	if spec.ServerAuth.TTL == 0 {
+		spec.ServerAuth.TTL = DefaultServerAuthTTL
+	}
+
+	if spec.Tracker == nil {
+		spec.Tracker = &TrackerOption{}
+	}
+	if spec.Tracker.Replicas == nil {
+		replicas := int32(DefaultTrackerReplicas)
+		spec.Tracker.Replicas = &replicas
+	}
+
+	// missing pod and storage sections are rejected by validation instead
+	if spec.Pod != nil && spec.Pod.ImagePullPolicy == "" {
+		spec.Pod.ImagePullPolicy = corev1.PullIfNotPresent
+	}
+	if spec.Storage != nil {
+		if spec.Storage.Unit == "" {
+			spec.Storage.Unit = DefaultStorageUnit
+		}
+		if spec.Storage.VolumeReclaimPolicy == "" {
+			spec.Storage.VolumeReclaimPolicy = VolumeReclaimPolicyRetain
+		}
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-fastdfs-beordie-cn-v1-fastdfs,mutating=false,failurePolicy=fail,sideEffects=None,groups=fastdfs.beordie.cn,resources=fastdfses,verbs=create;update,versions=v1,name=vfastdfs.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &FastDFS{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (cluster *FastDFS) ValidateCreate() error {
+	fastdfslog.Info("validate create", "name", cluster.Name)
+
+	return cluster.toInvalidError(cluster.validateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (cluster *FastDFS) ValidateUpdate(old runtime.Object) error {
+	fastdfslog.Info("validate update", "name", cluster.Name)
+
+	oldCluster, ok := old.(*FastDFS)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a FastDFS but got a %T", old))
+	}
+
+	allErrs := cluster.validateSpec()
+	allErrs = append(allErrs, cluster.validateStorageUpdate(oldCluster)...)
+	return cluster.toInvalidError(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (cluster *FastDFS) ValidateDelete() error {
+	return nil
+}
+
+func (cluster *FastDFS) toInvalidError(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("FastDFS").GroupKind(), cluster.Name, allErrs)
+}
+
+func (cluster *FastDFS) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	allErrs = append(allErrs, validateVersion(cluster.Spec.Version, specPath.Child("version"))...)
+
+	allErrs = append(allErrs, cluster.validateReplicas(specPath)...)
+
+	serverAuthPath := specPath.Child("serverAuth")
+	if len(cluster.Spec.ServerAuth.Secret) > MaxServerAuthSecretSize {
+		allErrs = append(allErrs, field.TooLong(serverAuthPath.Child("secret"), "", MaxServerAuthSecretSize))
+	}
+	if cluster.Spec.ServerAuth.Secret != "" && cluster.Spec.ServerAuth.SecretRef != nil {
+		allErrs = append(allErrs, field.Forbidden(serverAuthPath.Child("secretRef"), "may not be set together with secret"))
+	}
+	// mod_fastdfs serves the fallback instead of a 400, so gateways could not retry
+	// tokens signed with a replaced anti-steal key
+	if cluster.Spec.ServerAuth.FailFallBack != "" && cluster.Spec.Gateway != nil {
+		allErrs = append(allErrs, field.Forbidden(serverAuthPath.Child("failFallBack"),
+			"may not be set together with gateway, tokens of a replaced anti-steal key would be rejected"))
+	}
+	// sidecars never serve the previous key, a replaced key is dropped at once
+	if grace := cluster.Spec.ServerAuth.RotationGracePeriodSeconds; grace != nil && *grace > 0 &&
+		cluster.GetGatewayMode() == GatewayModeSidecar {
+		allErrs = append(allErrs, field.Forbidden(serverAuthPath.Child("rotationGracePeriodSeconds"),
+			"is only supported by the standalone gateway"))
+	}
+
+	if tracker := cluster.Spec.Tracker; tracker != nil && tracker.Config != nil {
+		config := tracker.Config
+		if config.StoreLookup != nil && *config.StoreLookup == 1 && config.StoreGroup == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("tracker", "config", "storeGroup"),
+				"store group must be specified when storeLookup is 1"))
+		}
+	}
+
+	if cluster.Spec.Pod == nil {
+		allErrs = append(allErrs, field.Required(specPath.Child("pod"), "pod options must be specified"))
+	}
+
+	storagePath := specPath.Child("storage")
+	if cluster.Spec.Storage == nil {
+		allErrs = append(allErrs, field.Required(storagePath, "storage options must be specified"))
+		return allErrs
+	}
+	if cluster.Spec.Storage.DiskSize < 1 {
+		allErrs = append(allErrs, field.Invalid(storagePath.Child("diskSize"), cluster.Spec.Storage.DiskSize,
+			"must be greater than or equal to 1"))
+	}
+
+	if cluster.GetStorageHTTPPort() == cluster.GetStoragePort() {
+		allErrs = append(allErrs, field.Invalid(storagePath.Child("httpPort"), cluster.GetStorageHTTPPort(),
+			"must differ from the storage port"))
+	}
+
+	if cluster.Spec.Storage.ExternalService != nil && versionPattern.MatchString(cluster.Spec.Version) &&
+		cluster.GetMajorVersion() < MultiIPVersion {
+		allErrs = append(allErrs, field.Forbidden(storagePath.Child("externalService"),
+			fmt.Sprintf("requires fastdfs %d or later to advertise external addresses", MultiIPVersion)))
+	}
+	if tracker := cluster.Spec.Tracker; cluster.Spec.Storage.ExternalService != nil && tracker != nil &&
+		tracker.Config != nil && tracker.Config.UseStorageId != nil && !*tracker.Config.UseStorageId {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("tracker", "config", "useStorageId"),
+			"must be enabled to advertise external addresses"))
+	}
+
+	if cluster.Spec.Gateway != nil {
+		portPath := specPath.Child("gateway", "port")
+		port := cluster.GetGatewayPort()
+		switch {
+		case port == PreviousGatewayPort:
+			allErrs = append(allErrs, field.Invalid(portPath, port,
+				"is reserved for the gateway accepting the previous anti-steal key"))
+		case cluster.GetGatewayMode() == GatewayModeSidecar &&
+			(port == cluster.GetStoragePort() || port == cluster.GetStorageHTTPPort()):
+			allErrs = append(allErrs, field.Invalid(portPath, port, "must differ from the storage ports in sidecar mode"))
+		}
+	}
+
+	if policy := cluster.Spec.NetworkPolicy; policy != nil {
+		policyPath := specPath.Child("networkPolicy")
+		// servers in the host network connect from node addresses, which neither
+		// allow_hosts nor the pod selectors of the policies match
+		if cluster.IsHostNetwork() {
+			allErrs = append(allErrs, field.Forbidden(policyPath, "may not be set together with pod.hostNetwork"))
+		}
+		for i, cidr := range policy.IPBlocks {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				allErrs = append(allErrs, field.Invalid(policyPath.Child("ipBlocks").Index(i), cidr, err.Error()))
+			}
+		}
+		for i, cidr := range policy.PodCIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				allErrs = append(allErrs, field.Invalid(policyPath.Child("podCIDRs").Index(i), cidr, err.Error()))
+			}
+		}
+	}
+
+	if cluster.Spec.Gateway != nil && cluster.Spec.Gateway.Ingress != nil {
+		allErrs = append(allErrs, cluster.validateIngress(specPath.Child("gateway", "ingress"))...)
+	}
+
+	names := map[string]bool{}
+	for i, group := range cluster.Spec.Storage.Groups {
+		if names[group.Name] {
+			allErrs = append(allErrs, field.Duplicate(storagePath.Child("groups").Index(i).Child("name"), group.Name))
+		}
+		names[group.Name] = true
+		if group.Replicas != nil && *group.Replicas >= StorageIDBlockSize {
+			allErrs = append(allErrs, field.Invalid(storagePath.Child("groups").Index(i).Child("replicas"),
+				*group.Replicas, fmt.Sprintf("must be less than %d", StorageIDBlockSize)))
+		}
+	}
+	return allErrs
+}
+
+func (cluster *FastDFS) validateIngress(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	ingress := cluster.Spec.Gateway.Ingress
+	if cluster.GetIngressKind() == IngressKindHTTPRoute {
+		if len(ingress.ParentRefs) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("parentRefs"), "an HTTPRoute attaches to gateways"))
+		}
+		if ingress.TLSSecretName != "" || ingress.Issuer != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("tlsSecretName"),
+				"TLS of an HTTPRoute is configured on the listeners of its parent gateways"))
+		}
+		if ingress.ClassName != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("className"), "only applies to Ingress"))
+		}
+	} else if len(ingress.ParentRefs) != 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("parentRefs"), "only applies to HTTPRoute"))
+	}
+	if (ingress.TLSSecretName != "" || ingress.Issuer != nil) && len(ingress.Hostnames) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("hostnames"), "certificates are issued for hostnames"))
+	}
+
+	groups := map[string]bool{}
+	for _, group := range cluster.GetStorageGroups() {
+		groups[group.Name] = true
+	}
+	for i, group := range ingress.Groups {
+		if !groups[group] {
+			allErrs = append(allErrs, field.NotFound(fldPath.Child("groups").Index(i), group))
+		}
+	}
+	return allErrs
+}
+
+func validateVersion(version string, fldPath *field.Path) field.ErrorList {
+	if version == "" {
+		return field.ErrorList{field.Required(fldPath, "version must be specified")}
+	}
+	if !versionPattern.MatchString(version) {
+		return field.ErrorList{field.Invalid(fldPath, version, "must be a FastDFS release such as 6.12")}
+	}
+	// v6.12 and 6.12 name the same release
+	for _, unsupported := range UnsupportedVersions {
+		if strings.TrimPrefix(version, "v") == unsupported {
+			return field.ErrorList{field.NotSupported(fldPath, version, nil)}
+		}
+	}
+	return nil
+}
+
+// validateReplicas checks spec.replicas, which the scale subresource changes as well
+func (cluster *FastDFS) validateReplicas(specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if cluster.Spec.Replicas != nil && cluster.Spec.ParticipantReplicas != nil &&
+		*cluster.Spec.ParticipantReplicas > *cluster.Spec.Replicas {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("participantReplicas"), *cluster.Spec.ParticipantReplicas,
+			fmt.Sprintf("must be less than or equal to replicas %d", *cluster.Spec.Replicas)))
+	}
+	// every group owns a block of storage ids, a larger group would take the ids of the next one
+	if cluster.Spec.Replicas != nil && *cluster.Spec.Replicas >= StorageIDBlockSize {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), *cluster.Spec.Replicas,
+			fmt.Sprintf("must be less than %d", StorageIDBlockSize)))
+	}
+	return allErrs
+}
+
+// validateStorageUpdate rejects storage changes that can not be applied to existing volumes
+func (cluster *FastDFS) validateStorageUpdate(old *FastDFS) field.ErrorList {
+	var allErrs field.ErrorList
+	if cluster.Spec.Storage == nil || old.Spec.Storage == nil {
+		return allErrs
+	}
+	storagePath := field.NewPath("spec", "storage")
+
+	diskSizeShrunk := cluster.Spec.Storage.DiskSize < old.Spec.Storage.DiskSize
+	if diskSizeShrunk {
+		allErrs = append(allErrs, field.Forbidden(storagePath.Child("diskSize"),
+			fmt.Sprintf("can not be shrunk from %d to %d", old.Spec.Storage.DiskSize, cluster.Spec.Storage.DiskSize)))
+	}
+	storageClassChanged := !isStorageClassEqual(cluster.Spec.Storage.StorageClass, old.Spec.Storage.StorageClass)
+	if storageClassChanged {
+		allErrs = append(allErrs, field.Forbidden(storagePath.Child("storageClass"), "can not be changed after creation"))
+	}
+
+	oldGroups := map[string]StorageGroup{}
+	for _, group := range old.GetStorageGroups() {
+		oldGroups[group.Name] = group
+	}
+	newGroups := map[string]StorageGroup{}
+	for _, group := range cluster.GetStorageGroups() {
+		newGroups[group.Name] = group
+	}
+	// the pods of a removed group would lose their storage.conf while still holding files
+	for _, group := range old.GetStorageGroups() {
+		if _, ok := newGroups[group.Name]; !ok {
+			allErrs = append(allErrs, field.Forbidden(storagePath.Child("groups"),
+				fmt.Sprintf("group %s can not be removed, scale it to 0 replicas instead", group.Name)))
+		}
+	}
+	for i, group := range cluster.Spec.Storage.Groups {
+		oldGroup, ok := oldGroups[group.Name]
+		if !ok {
+			continue
+		}
+		newGroup := newGroups[group.Name]
+		groupPath := storagePath.Child("groups").Index(i)
+
+		// inherited values were already reported on the storage level
+		if newGroup.DiskSize < oldGroup.DiskSize && (group.DiskSize != 0 || !diskSizeShrunk) {
+			allErrs = append(allErrs, field.Forbidden(groupPath.Child("diskSize"),
+				fmt.Sprintf("can not be shrunk from %d to %d", oldGroup.DiskSize, newGroup.DiskSize)))
+		}
+		if !isStorageClassEqual(newGroup.StorageClass, oldGroup.StorageClass) &&
+			(group.StorageClass != nil || !storageClassChanged) {
+			allErrs = append(allErrs, field.Forbidden(groupPath.Child("storageClass"), "can not be changed after creation"))
+		}
+	}
+	return allErrs
+}
+
+func isStorageClassEqual(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
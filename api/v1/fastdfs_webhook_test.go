@@ -0,0 +1,274 @@
+package v1
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// newTestCluster is a defaulted cluster passing validation
+func newTestCluster() *FastDFS {
+	cluster := &FastDFS{
+		ObjectMeta: metav1.ObjectMeta{Name: "fdfs", Namespace: "default"},
+		Spec: FastDFSSpec{
+			Version: "6.12",
+			Pod:     &PodOption{},
+			Storage: &StorageOption{DiskSize: 10},
+		},
+	}
+	cluster.Default()
+	return cluster
+}
+
+// errorFields is the paths of the errors, in order
+func errorFields(errs field.ErrorList) []string {
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	return fields
+}
+
+func TestValidateVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		valid   bool
+	}{
+		{"6.12", true},
+		{"v6.12", true},
+		{"6.12.1", true},
+		{"v6.12.1", true},
+		{"5.11", true},
+		{"3.6.3", false},
+		{"v3.6.3", false},
+		{"6", false},
+		{"latest", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			errs := validateVersion(tt.version, field.NewPath("spec", "version"))
+			if valid := len(errs) == 0; valid != tt.valid {
+				t.Errorf("validateVersion(%q) = %v, want valid %v", tt.version, errs, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(cluster *FastDFS)
+		want   []string
+	}{
+		{"valid", func(cluster *FastDFS) {}, nil},
+		{"participant replicas above replicas", func(cluster *FastDFS) {
+			cluster.Spec.ParticipantReplicas = int32Ptr(2)
+		}, []string{"spec.participantReplicas"}},
+		{"replicas beyond the storage id block", func(cluster *FastDFS) {
+			cluster.Spec.Replicas = int32Ptr(StorageIDBlockSize)
+			cluster.Spec.ParticipantReplicas = int32Ptr(1)
+		}, []string{"spec.replicas"}},
+		{"group replicas beyond the storage id block", func(cluster *FastDFS) {
+			cluster.Spec.Storage.Groups = []StorageGroup{
+				{Name: "group1", Replicas: int32Ptr(StorageIDBlockSize - 1)},
+				{Name: "group2", Replicas: int32Ptr(StorageIDBlockSize)},
+			}
+		}, []string{"spec.storage.groups[1].replicas"}},
+		{"duplicate groups", func(cluster *FastDFS) {
+			cluster.Spec.Storage.Groups = []StorageGroup{{Name: "group1"}, {Name: "group1"}}
+		}, []string{"spec.storage.groups[1].name"}},
+		{"secret and secret ref", func(cluster *FastDFS) {
+			cluster.Spec.ServerAuth.Secret = "key"
+			cluster.Spec.ServerAuth.SecretRef = &corev1.SecretKeySelector{}
+		}, []string{"spec.serverAuth.secretRef"}},
+		{"fail fallback with gateway", func(cluster *FastDFS) {
+			cluster.Spec.ServerAuth.FailFallBack = "/denied.jpg"
+			cluster.Spec.Gateway = &GatewayOption{}
+		}, []string{"spec.serverAuth.failFallBack"}},
+		{"rotation grace period with sidecars", func(cluster *FastDFS) {
+			cluster.Spec.ServerAuth.RotationGracePeriodSeconds = int32Ptr(60)
+			cluster.Spec.Gateway = &GatewayOption{Mode: GatewayModeSidecar, Port: int32Ptr(8080)}
+		}, []string{"spec.serverAuth.rotationGracePeriodSeconds"}},
+		{"rotation grace period with the standalone gateway", func(cluster *FastDFS) {
+			cluster.Spec.ServerAuth.RotationGracePeriodSeconds = int32Ptr(60)
+			cluster.Spec.Gateway = &GatewayOption{}
+		}, nil},
+		{"no rotation grace period with sidecars", func(cluster *FastDFS) {
+			cluster.Spec.ServerAuth.RotationGracePeriodSeconds = int32Ptr(0)
+			cluster.Spec.Gateway = &GatewayOption{Mode: GatewayModeSidecar, Port: int32Ptr(8080)}
+		}, nil},
+		{"store lookup 1 without store group", func(cluster *FastDFS) {
+			cluster.Spec.Tracker.Config = &TrackerConfig{StoreLookup: int32Ptr(1)}
+		}, []string{"spec.tracker.config.storeGroup"}},
+		{"missing pod", func(cluster *FastDFS) {
+			cluster.Spec.Pod = nil
+		}, []string{"spec.pod"}},
+		{"missing storage", func(cluster *FastDFS) {
+			cluster.Spec.Storage = nil
+		}, []string{"spec.storage"}},
+		{"empty disk", func(cluster *FastDFS) {
+			cluster.Spec.Storage.DiskSize = 0
+		}, []string{"spec.storage.diskSize"}},
+		{"http port equal to storage port", func(cluster *FastDFS) {
+			cluster.Spec.Storage.HTTPPort = int32Ptr(DefaultStoragePort)
+		}, []string{"spec.storage.httpPort"}},
+		{"external service before multiple addresses", func(cluster *FastDFS) {
+			cluster.Spec.Version = "v5.11"
+			cluster.Spec.Storage.ExternalService = &ExternalServiceOption{}
+		}, []string{"spec.storage.externalService"}},
+		{"external service without storage ids", func(cluster *FastDFS) {
+			cluster.Spec.Storage.ExternalService = &ExternalServiceOption{}
+			cluster.Spec.Tracker.Config = &TrackerConfig{UseStorageId: new(bool)}
+		}, []string{"spec.tracker.config.useStorageId"}},
+		{"gateway on the previous gateway port", func(cluster *FastDFS) {
+			cluster.Spec.Gateway = &GatewayOption{Port: int32Ptr(PreviousGatewayPort)}
+		}, []string{"spec.gateway.port"}},
+		{"sidecar gateway on the storage port", func(cluster *FastDFS) {
+			cluster.Spec.Gateway = &GatewayOption{Mode: GatewayModeSidecar, Port: int32Ptr(DefaultStoragePort)}
+		}, []string{"spec.gateway.port"}},
+		{"malformed network policy cidrs", func(cluster *FastDFS) {
+			cluster.Spec.NetworkPolicy = &NetworkPolicyOption{
+				IPBlocks: []string{"10.0.0.0/8", "10.0.0.1"},
+				PodCIDRs: []string{"pods"},
+			}
+		}, []string{"spec.networkPolicy.ipBlocks[1]", "spec.networkPolicy.podCIDRs[0]"}},
+		{"network policy in the host network", func(cluster *FastDFS) {
+			cluster.Spec.Pod.HostNetwork = true
+			cluster.Spec.NetworkPolicy = &NetworkPolicyOption{}
+		}, []string{"spec.networkPolicy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster()
+			tt.mutate(cluster)
+			if got := errorFields(cluster.validateSpec()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateSpec() fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStorageUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(cluster *FastDFS)
+		want   []string
+	}{
+		{"unchanged", func(cluster *FastDFS) {}, nil},
+		{"disk grown", func(cluster *FastDFS) {
+			cluster.Spec.Storage.DiskSize = 20
+		}, nil},
+		{"disk shrunk", func(cluster *FastDFS) {
+			cluster.Spec.Storage.DiskSize = 5
+		}, []string{"spec.storage.diskSize"}},
+		{"storage class changed", func(cluster *FastDFS) {
+			cluster.Spec.Storage.StorageClass = stringPtr("fast")
+		}, []string{"spec.storage.storageClass"}},
+		{"group appended", func(cluster *FastDFS) {
+			cluster.Spec.Storage.Groups = append(cluster.Spec.Storage.Groups, StorageGroup{Name: "group3"})
+		}, nil},
+		{"group removed", func(cluster *FastDFS) {
+			cluster.Spec.Storage.Groups = cluster.Spec.Storage.Groups[:1]
+		}, []string{"spec.storage.groups"}},
+		{"group disk shrunk", func(cluster *FastDFS) {
+			cluster.Spec.Storage.Groups[1].DiskSize = 5
+		}, []string{"spec.storage.groups[1].diskSize"}},
+		{"inherited disk shrink reported once", func(cluster *FastDFS) {
+			cluster.Spec.Storage.DiskSize = 5
+		}, []string{"spec.storage.diskSize"}},
+		{"group storage class changed", func(cluster *FastDFS) {
+			cluster.Spec.Storage.Groups[0].StorageClass = stringPtr("fast")
+		}, []string{"spec.storage.groups[0].storageClass"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := newTestCluster()
+			old.Spec.Storage.Groups = []StorageGroup{{Name: "group1"}, {Name: "group2"}}
+			cluster := old.DeepCopy()
+			tt.mutate(cluster)
+			if got := errorFields(cluster.validateStorageUpdate(old)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateStorageUpdate() fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  FastDFSSpec
+		check func(t *testing.T, spec *FastDFSSpec)
+	}{
+		{"empty", FastDFSSpec{}, func(t *testing.T, spec *FastDFSSpec) {
+			if *spec.Replicas != DefaultReplicas || *spec.ParticipantReplicas != DefaultReplicas {
+				t.Errorf("replicas = %d/%d, want %d", *spec.Replicas, *spec.ParticipantReplicas, DefaultReplicas)
+			}
+			if spec.ServerAuth.TTL != DefaultServerAuthTTL {
+				t.Errorf("ttl = %d, want %d", spec.ServerAuth.TTL, DefaultServerAuthTTL)
+			}
+			if spec.Tracker == nil || *spec.Tracker.Replicas != DefaultTrackerReplicas {
+				t.Errorf("tracker = %+v, want %d replicas", spec.Tracker, DefaultTrackerReplicas)
+			}
+			if spec.Pod != nil || spec.Storage != nil {
+				t.Errorf("pod and storage are left to validation, got %+v %+v", spec.Pod, spec.Storage)
+			}
+		}},
+		{"participants follow replicas", FastDFSSpec{Replicas: int32Ptr(3)}, func(t *testing.T, spec *FastDFSSpec) {
+			if *spec.ParticipantReplicas != 3 {
+				t.Errorf("participantReplicas = %d, want 3", *spec.ParticipantReplicas)
+			}
+		}},
+		{"set values kept", FastDFSSpec{
+			Replicas:            int32Ptr(3),
+			ParticipantReplicas: int32Ptr(2),
+			ServerAuth:          ServerAuth{TTL: 60},
+			Tracker:             &TrackerOption{Replicas: int32Ptr(2)},
+			Pod:                 &PodOption{ImagePullPolicy: corev1.PullAlways},
+			Storage:             &StorageOption{Unit: "Ti", VolumeReclaimPolicy: VolumeReclaimPolicyDelete},
+		}, func(t *testing.T, spec *FastDFSSpec) {
+			if *spec.Replicas != 3 || *spec.ParticipantReplicas != 2 || spec.ServerAuth.TTL != 60 ||
+				*spec.Tracker.Replicas != 2 {
+				t.Errorf("defaults replaced set values: %+v", spec)
+			}
+			if spec.Pod.ImagePullPolicy != corev1.PullAlways || spec.Storage.Unit != "Ti" ||
+				spec.Storage.VolumeReclaimPolicy != VolumeReclaimPolicyDelete {
+				t.Errorf("defaults replaced set values: %+v %+v", spec.Pod, spec.Storage)
+			}
+		}},
+		{"pod and storage", FastDFSSpec{Pod: &PodOption{}, Storage: &StorageOption{}}, func(t *testing.T, spec *FastDFSSpec) {
+			if spec.Pod.ImagePullPolicy != corev1.PullIfNotPresent {
+				t.Errorf("imagePullPolicy = %s, want %s", spec.Pod.ImagePullPolicy, corev1.PullIfNotPresent)
+			}
+			if spec.Storage.Unit != DefaultStorageUnit || spec.Storage.VolumeReclaimPolicy != VolumeReclaimPolicyRetain {
+				t.Errorf("storage = %+v, want unit %s and reclaim policy %s", spec.Storage,
+					DefaultStorageUnit, VolumeReclaimPolicyRetain)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &FastDFS{Spec: tt.spec}
+			cluster.Default()
+			tt.check(t, &cluster.Spec)
+
+			// defaulting again changes nothing
+			again := cluster.DeepCopy()
+			again.Default()
+			if !reflect.DeepEqual(again.Spec, cluster.Spec) {
+				t.Errorf("Default() is not idempotent: %+v, want %+v", again.Spec, cluster.Spec)
+			}
+		})
+	}
+}
@@ -23,9 +23,72 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointsStatus) DeepCopyInto(out *EndpointsStatus) {
+	*out = *in
+	if in.TrackerExternal != nil {
+		in, out := &in.TrackerExternal, &out.TrackerExternal
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = make([]StorageEndpoint, len(*in))
+		copy(*out, *in)
+	}
+	if in.Gateway != nil {
+		in, out := &in.Gateway, &out.Gateway
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GatewayExternal != nil {
+		in, out := &in.GatewayExternal, &out.GatewayExternal
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointsStatus.
+func (in *EndpointsStatus) DeepCopy() *EndpointsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalServiceOption) DeepCopyInto(out *ExternalServiceOption) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalServiceOption.
+func (in *ExternalServiceOption) DeepCopy() *ExternalServiceOption {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalServiceOption)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FastDFS) DeepCopyInto(out *FastDFS) {
 	*out = *in
@@ -88,7 +151,7 @@ func (in *FastDFSList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FastDFSSpec) DeepCopyInto(out *FastDFSSpec) {
 	*out = *in
-	out.ServerAuth = in.ServerAuth
+	in.ServerAuth.DeepCopyInto(&out.ServerAuth)
 	if in.Replicas != nil {
 		in, out := &in.Replicas, &out.Replicas
 		*out = new(int32)
@@ -99,11 +162,26 @@ func (in *FastDFSSpec) DeepCopyInto(out *FastDFSSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.Tracker != nil {
+		in, out := &in.Tracker, &out.Tracker
+		*out = new(TrackerOption)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Storage != nil {
 		in, out := &in.Storage, &out.Storage
 		*out = new(StorageOption)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Gateway != nil {
+		in, out := &in.Gateway, &out.Gateway
+		*out = new(GatewayOption)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(NetworkPolicyOption)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Labels != nil {
 		in, out := &in.Labels, &out.Labels
 		*out = make(map[string]string, len(*in))
@@ -159,6 +237,28 @@ func (in *FastDFSStatus) DeepCopyInto(out *FastDFSStatus) {
 		in, out := &in.LastScheduleTime, &out.LastScheduleTime
 		*out = (*in).DeepCopy()
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.Tracker = in.Tracker
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]StorageGroupStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.PausedReplicas != nil {
+		in, out := &in.PausedReplicas, &out.PausedReplicas
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Endpoints.DeepCopyInto(&out.Endpoints)
+	in.ServerAuth.DeepCopyInto(&out.ServerAuth)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FastDFSStatus.
@@ -171,6 +271,89 @@ func (in *FastDFSStatus) DeepCopy() *FastDFSStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayIngressOption) DeepCopyInto(out *GatewayIngressOption) {
+	*out = *in
+	if in.ClassName != nil {
+		in, out := &in.ClassName, &out.ClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.ParentRefs != nil {
+		in, out := &in.ParentRefs, &out.ParentRefs
+		*out = make([]ParentReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Hostnames != nil {
+		in, out := &in.Hostnames, &out.Hostnames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Issuer != nil {
+		in, out := &in.Issuer, &out.Issuer
+		*out = new(IssuerReference)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayIngressOption.
+func (in *GatewayIngressOption) DeepCopy() *GatewayIngressOption {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayIngressOption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayOption) DeepCopyInto(out *GatewayOption) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ServiceOption)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(GatewayIngressOption)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayOption.
+func (in *GatewayOption) DeepCopy() *GatewayOption {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayOption)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Image) DeepCopyInto(out *Image) {
 	*out = *in
@@ -186,6 +369,93 @@ func (in *Image) DeepCopy() *Image {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IssuerReference) DeepCopyInto(out *IssuerReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IssuerReference.
+func (in *IssuerReference) DeepCopy() *IssuerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(IssuerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyClient) DeepCopyInto(out *NetworkPolicyClient) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodSelector != nil {
+		in, out := &in.PodSelector, &out.PodSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyClient.
+func (in *NetworkPolicyClient) DeepCopy() *NetworkPolicyClient {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyClient)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyOption) DeepCopyInto(out *NetworkPolicyOption) {
+	*out = *in
+	if in.Clients != nil {
+		in, out := &in.Clients, &out.Clients
+		*out = make([]NetworkPolicyClient, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.IPBlocks != nil {
+		in, out := &in.IPBlocks, &out.IPBlocks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PodCIDRs != nil {
+		in, out := &in.PodCIDRs, &out.PodCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyOption.
+func (in *NetworkPolicyOption) DeepCopy() *NetworkPolicyOption {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyOption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ParentReference) DeepCopyInto(out *ParentReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ParentReference.
+func (in *ParentReference) DeepCopy() *ParentReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ParentReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodOption) DeepCopyInto(out *PodOption) {
 	*out = *in
@@ -213,6 +483,21 @@ func (in *PodOption) DeepCopy() *PodOption {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServerAuth) DeepCopyInto(out *ServerAuth) {
 	*out = *in
+	if in.TokenRef != nil {
+		in, out := &in.TokenRef, &out.TokenRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RotationGracePeriodSeconds != nil {
+		in, out := &in.RotationGracePeriodSeconds, &out.RotationGracePeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerAuth.
@@ -225,6 +510,197 @@ func (in *ServerAuth) DeepCopy() *ServerAuth {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerAuthStatus) DeepCopyInto(out *ServerAuthStatus) {
+	*out = *in
+	if in.RotationStartTime != nil {
+		in, out := &in.RotationStartTime, &out.RotationStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PreviousKeyExpirationTime != nil {
+		in, out := &in.PreviousKeyExpirationTime, &out.PreviousKeyExpirationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastRotationTime != nil {
+		in, out := &in.LastRotationTime, &out.LastRotationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerAuthStatus.
+func (in *ServerAuthStatus) DeepCopy() *ServerAuthStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerAuthStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceOption) DeepCopyInto(out *ServiceOption) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodePorts != nil {
+		in, out := &in.NodePorts, &out.NodePorts
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceOption.
+func (in *ServiceOption) DeepCopy() *ServiceOption {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceOption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageConfig) DeepCopyInto(out *StorageConfig) {
+	*out = *in
+	if in.MaxConnections != nil {
+		in, out := &in.MaxConnections, &out.MaxConnections
+		*out = new(int32)
+		**out = **in
+	}
+	if in.WorkThreads != nil {
+		in, out := &in.WorkThreads, &out.WorkThreads
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DiskRwSeparated != nil {
+		in, out := &in.DiskRwSeparated, &out.DiskRwSeparated
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DiskReaderThreads != nil {
+		in, out := &in.DiskReaderThreads, &out.DiskReaderThreads
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DiskWriterThreads != nil {
+		in, out := &in.DiskWriterThreads, &out.DiskWriterThreads
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SyncWaitMsec != nil {
+		in, out := &in.SyncWaitMsec, &out.SyncWaitMsec
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SyncInterval != nil {
+		in, out := &in.SyncInterval, &out.SyncInterval
+		*out = new(int32)
+		**out = **in
+	}
+	if in.WriteMarkFileFreq != nil {
+		in, out := &in.WriteMarkFileFreq, &out.WriteMarkFileFreq
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SyncLogBuffInterval != nil {
+		in, out := &in.SyncLogBuffInterval, &out.SyncLogBuffInterval
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SyncBinlogBuffInterval != nil {
+		in, out := &in.SyncBinlogBuffInterval, &out.SyncBinlogBuffInterval
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SyncStatFileInterval != nil {
+		in, out := &in.SyncStatFileInterval, &out.SyncStatFileInterval
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FsyncAfterWrittenBytes != nil {
+		in, out := &in.FsyncAfterWrittenBytes, &out.FsyncAfterWrittenBytes
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageConfig.
+func (in *StorageConfig) DeepCopy() *StorageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageEndpoint) DeepCopyInto(out *StorageEndpoint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageEndpoint.
+func (in *StorageEndpoint) DeepCopy() *StorageEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageGroup) DeepCopyInto(out *StorageGroup) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.StorageClass != nil {
+		in, out := &in.StorageClass, &out.StorageClass
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageGroup.
+func (in *StorageGroup) DeepCopy() *StorageGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageGroupStatus) DeepCopyInto(out *StorageGroupStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageGroupStatus.
+func (in *StorageGroupStatus) DeepCopy() *StorageGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StorageOption) DeepCopyInto(out *StorageOption) {
 	*out = *in
@@ -233,6 +709,46 @@ func (in *StorageOption) DeepCopyInto(out *StorageOption) {
 		*out = new(string)
 		**out = **in
 	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.HTTPPort != nil {
+		in, out := &in.HTTPPort, &out.HTTPPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(StorageConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigOverrides != nil {
+		in, out := &in.ConfigOverrides, &out.ConfigOverrides
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ExternalService != nil {
+		in, out := &in.ExternalService, &out.ExternalService
+		*out = new(ExternalServiceOption)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]StorageGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageOption.
@@ -244,3 +760,141 @@ func (in *StorageOption) DeepCopy() *StorageOption {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrackerConfig) DeepCopyInto(out *TrackerConfig) {
+	*out = *in
+	if in.StoreLookup != nil {
+		in, out := &in.StoreLookup, &out.StoreLookup
+		*out = new(int32)
+		**out = **in
+	}
+	if in.StoreServer != nil {
+		in, out := &in.StoreServer, &out.StoreServer
+		*out = new(int32)
+		**out = **in
+	}
+	if in.StorePath != nil {
+		in, out := &in.StorePath, &out.StorePath
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DownloadServer != nil {
+		in, out := &in.DownloadServer, &out.DownloadServer
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxConnections != nil {
+		in, out := &in.MaxConnections, &out.MaxConnections
+		*out = new(int32)
+		**out = **in
+	}
+	if in.WorkThreads != nil {
+		in, out := &in.WorkThreads, &out.WorkThreads
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SyncLogBuffInterval != nil {
+		in, out := &in.SyncLogBuffInterval, &out.SyncLogBuffInterval
+		*out = new(int32)
+		**out = **in
+	}
+	if in.CheckActiveInterval != nil {
+		in, out := &in.CheckActiveInterval, &out.CheckActiveInterval
+		*out = new(int32)
+		**out = **in
+	}
+	if in.UseStorageId != nil {
+		in, out := &in.UseStorageId, &out.UseStorageId
+		*out = new(bool)
+		**out = **in
+	}
+	if in.UseTrunkFile != nil {
+		in, out := &in.UseTrunkFile, &out.UseTrunkFile
+		*out = new(bool)
+		**out = **in
+	}
+	if in.TrunkCreateFileAdvance != nil {
+		in, out := &in.TrunkCreateFileAdvance, &out.TrunkCreateFileAdvance
+		*out = new(bool)
+		**out = **in
+	}
+	if in.TrunkCreateFileInterval != nil {
+		in, out := &in.TrunkCreateFileInterval, &out.TrunkCreateFileInterval
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrackerConfig.
+func (in *TrackerConfig) DeepCopy() *TrackerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TrackerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrackerOption) DeepCopyInto(out *TrackerOption) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(TrackerConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigOverrides != nil {
+		in, out := &in.ConfigOverrides, &out.ConfigOverrides
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ServiceOption)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrackerOption.
+func (in *TrackerOption) DeepCopy() *TrackerOption {
+	if in == nil {
+		return nil
+	}
+	out := new(TrackerOption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrackerStatus) DeepCopyInto(out *TrackerStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrackerStatus.
+func (in *TrackerStatus) DeepCopy() *TrackerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TrackerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
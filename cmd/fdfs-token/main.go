@@ -0,0 +1,123 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// fdfs-token signs and verifies FastDFS download urls with the anti-steal key
+// the operator configured for a cluster.
+//
+//	fdfs-token -cluster fastdfs-sample generate group1/M00/00/00/xxx.jpg
+//	fdfs-token -cluster fastdfs-sample -token <token> -ts <ts> verify group1/M00/00/00/xxx.jpg
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	fastdfsv1 "fastdfs_operator/api/v1"
+	"fastdfs_operator/pkg/fdfs/token"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(fastdfsv1.AddToScheme(scheme))
+}
+
+func main() {
+	var namespace, clusterName, signature string
+	var timestamp int64
+	flag.StringVar(&namespace, "namespace", "default", "The namespace of the FastDFS cluster.")
+	flag.StringVar(&clusterName, "cluster", "", "The name of the FastDFS cluster.")
+	flag.StringVar(&signature, "token", "", "The token to verify.")
+	flag.Int64Var(&timestamp, "ts", 0, "The unix timestamp the token is signed at, default now when generating.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] generate|verify <file id>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if clusterName == "" || flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	fileID := token.FileIDWithoutGroup(flag.Arg(1))
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		fail(err)
+	}
+	cluster := &fastdfsv1.FastDFS{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: clusterName}, cluster); err != nil {
+		fail(err)
+	}
+	// the rendered secret holds the resolved key whether it is generated or referenced
+	secret := &corev1.Secret{}
+	if err := c.Get(context.Background(), cluster.GetHTTPConfigSecretNamespacedName(), secret); err != nil {
+		fail(err)
+	}
+	key := string(secret.Data[fastdfsv1.AntiStealSecretKey])
+
+	switch flag.Arg(0) {
+	case "generate":
+		if timestamp == 0 {
+			timestamp = time.Now().Unix()
+		}
+		fmt.Println(token.Query(fileID, key, timestamp).Encode())
+	case "verify":
+		ttl := time.Duration(cluster.Spec.ServerAuth.TTL) * time.Second
+		if ttl == 0 {
+			ttl = fastdfsv1.DefaultServerAuthTTL * time.Second
+		}
+		err := token.Verify(fileID, key, signature, timestamp, ttl)
+		// tokens of the replaced key stay valid until the rotation grace period expires
+		if previous := previousKey(secret); previous != "" && errors.Is(err, token.ErrInvalidToken) {
+			err = token.Verify(fileID, previous, signature, timestamp, ttl)
+		}
+		if err != nil {
+			fail(err)
+		}
+		fmt.Println("valid")
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// previousKey is the replaced anti-steal key as long as the gateways accept it
+func previousKey(secret *corev1.Secret) string {
+	expiration, err := time.Parse(time.RFC3339, string(secret.Data[fastdfsv1.PreviousKeyExpirationKey]))
+	if err != nil || !time.Now().Before(expiration) {
+		return ""
+	}
+	return string(secret.Data[fastdfsv1.PreviousSecretKey])
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,654 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	v1 "fastdfs_operator/api/v1"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fastdfs_operator/pkg/utils"
+)
+
+// includeHTTPConf pulls the shared http.conf into a configuration file,
+// the leading # is part of the FastDFS include directive
+const includeHTTPConf = "#include " + v1.HTTPConfigFile + "\n"
+
+// includeNodeConf pulls the bind_addr written by storage servers in the host network at startup
+const includeNodeConf = "#include " + v1.NodeConfigFile + "\n"
+
+// storageIdsFile is the storage_ids.conf read by clients and gateways, mounted as a directory
+// so it follows scaling without restarting the pods
+const storageIdsFile = v1.StorageIdsDir + "/" + v1.StorageIdsConfigFile
+
+// managedTrackerParameters and managedStorageParameters are rendered from the
+// cluster, overriding them would break the deployment
+var (
+	managedTrackerParameters = []string{"disabled", "port", "base_path", "use_storage_id", "storage_ids_filename",
+		"allow_hosts", "bind_addr"}
+	managedStorageParameters = []string{"disabled", "group_name", "port", "base_path", "store_path_count",
+		"store_path0", "tracker_server", "http.server_port", "allow_hosts", "bind_addr"}
+)
+
+// trackerParametersSince and storageParametersSince are the parameters introduced
+// after FastDFS 5 keyed by the major version introducing them
+var (
+	trackerParametersSince = map[int][]string{
+		6: {"trunk_free_space_merge", "delete_unused_trunk_files", "trunk_compress_binlog_interval",
+			"trunk_compress_binlog_time_base", "trunk_binlog_max_backups", "response_ip_addr_size"},
+	}
+	storageParametersSince = map[int][]string{
+		6: {"disk_recovery_threads", "compress_binlog", "compress_binlog_time", "check_store_path_mode",
+			"trunk_binlog_max_backups"},
+	}
+)
+
+// confEntry is a single key=value line of a FastDFS configuration file
+type confEntry struct {
+	key   string
+	value string
+}
+
+// conf is an ordered FastDFS configuration file, rendering the same conf
+// always gives the same content so pods are only restarted on real changes
+type conf []confEntry
+
+// set replaces the value of key, or appends it when the key is unknown yet
+func (c conf) set(key, value string) conf {
+	for i := range c {
+		if c[i].key == key {
+			c[i].value = value
+			return c
+		}
+	}
+	return append(c, confEntry{key: key, value: value})
+}
+
+// setAll replaces every line of key with a line per value, in place of the first one
+func (c conf) setAll(key string, values []string) conf {
+	result := conf{}
+	done := false
+	for _, entry := range c {
+		if entry.key != key {
+			result = append(result, entry)
+			continue
+		}
+		if !done {
+			for _, value := range values {
+				result = append(result, confEntry{key: key, value: value})
+			}
+			done = true
+		}
+	}
+	if !done {
+		for _, value := range values {
+			result = append(result, confEntry{key: key, value: value})
+		}
+	}
+	return result
+}
+
+// apply sets every parameter of options that is set, options is a pointer
+// to a struct whose json field names are the camel case of the parameters
+func (c conf) apply(options interface{}) conf {
+	val := reflect.ValueOf(options)
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return c
+		}
+		val = val.Elem()
+	}
+	for i := 0; i < val.NumField(); i++ {
+		name := strings.Split(val.Type().Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if value, ok := confValue(val.Field(i)); ok {
+			c = c.set(utils.CamelToSnake(name), value)
+		}
+	}
+	return c
+}
+
+// override sets raw parameters in the order of their keys
+func (c conf) override(parameters map[string]string) conf {
+	keys := make([]string, 0, len(parameters))
+	for key := range parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		c = c.set(key, parameters[key])
+	}
+	return c
+}
+
+// forVersion drops the parameters introduced after the major release, the servers
+// refuse configuration files with parameters they do not know
+func (c conf) forVersion(since map[int][]string, major int) conf {
+	result := conf{}
+	for _, entry := range c {
+		if introduced := parameterIntroduction(since, entry.key); introduced <= major {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// has tells whether the conf knows the parameter
+func (c conf) has(key string) bool {
+	for _, entry := range c {
+		if entry.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (c conf) String() string {
+	var sb strings.Builder
+	for _, entry := range c {
+		sb.WriteString(entry.key)
+		sb.WriteString("=")
+		sb.WriteString(entry.value)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// confValue formats a field value, unset pointers and empty strings are skipped
+func confValue(field reflect.Value) (string, bool) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return "", false
+		}
+		field = field.Elem()
+	}
+	switch field.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(field.Int(), 10), true
+	case reflect.String:
+		return field.String(), field.String() != ""
+	}
+	return "", false
+}
+
+// trackerConfigFiles is the configmap keys mounted into tracker pods
+func trackerConfigFiles() []string {
+	return []string{v1.TrackerConfigFile, v1.StorageIdsConfigFile}
+}
+
+// storageConfigFiles is the configmap keys mounted into storage pods of a group
+func storageConfigFiles(cluster *v1.FastDFS, group string) []string {
+	files := []string{cluster.GetStorageConfigFileName(group), v1.ClientConfigFile, v1.HTTPConfigFile}
+	if cluster.GetGatewayMode() == v1.GatewayModeSidecar {
+		files = append(files, gatewayConfigFiles(cluster, cluster.GetModFastDFSConfigFileName(group))...)
+	}
+	return files
+}
+
+// gatewayConfigFiles is the configmap keys mounted into gateways reading the given mod_fastdfs.conf
+func gatewayConfigFiles(cluster *v1.FastDFS, modFastDFSConfigFile string) []string {
+	files := []string{v1.NginxConfigFile, modFastDFSConfigFile, v1.ClientConfigFile, v1.HTTPConfigFile}
+	if servesPreviousKey(cluster) {
+		files = append(files, v1.PreviousNginxConfigFile, v1.PreviousHTTPConfigFile)
+	}
+	return files
+}
+
+// isRotating tells whether tokens signed with the previous anti-steal key are still accepted
+func isRotating(cluster *v1.FastDFS) bool {
+	return cluster.Status.ServerAuth.Phase == v1.ServerAuthPhaseRotating
+}
+
+// servesPreviousKey tells whether gateways retry failed tokens against the previous key. Only the
+// standalone gateways do, a container coming and going with rotations would restart storage pods
+func servesPreviousKey(cluster *v1.FastDFS) bool {
+	return isRotating(cluster) && cluster.GetGatewayMode() == v1.GatewayModeStandalone
+}
+
+// makeConfigHash digests the rendered files, pods restart once the digest changes
+func makeConfigHash(data map[string]string, files []string) string {
+	h := sha256.New()
+	for _, file := range files {
+		h.Write([]byte(file + "\n" + data[file] + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// makeTrackerConf renders tracker.conf, parameters not in the spec keep the FastDFS defaults
+func makeTrackerConf(cluster *v1.FastDFS) string {
+	c := trackerConf(cluster)
+	if cluster.Spec.Tracker != nil {
+		c = c.override(cluster.Spec.Tracker.ConfigOverrides)
+	}
+	return c.forVersion(trackerParametersSince, cluster.GetMajorVersion()).String()
+}
+
+func trackerConf(cluster *v1.FastDFS) conf {
+	c := conf{
+		{"disabled", "false"},
+		{"bind_addr", ""},
+		{"port", strconv.Itoa(cluster.GetTrackerPort())},
+		{"connect_timeout", "10"},
+		{"network_timeout", "60"},
+		{"base_path", v1.DataDir},
+		{"max_connections", "1024"},
+		{"accept_threads", "1"},
+		{"work_threads", "4"},
+		{"min_buff_size", "8KB"},
+		{"max_buff_size", "128KB"},
+		{"store_lookup", "2"},
+		{"store_group", v1.DefaultStorageGroupName},
+		{"store_server", "0"},
+		{"store_path", "0"},
+		{"download_server", "0"},
+		{"reserved_storage_space", "20%"},
+		{"log_level", "info"},
+		{"run_by_group", ""},
+		{"run_by_user", ""},
+		{"allow_hosts", "*"},
+		{"sync_log_buff_interval", "10"},
+		{"check_active_interval", "120"},
+		{"thread_stack_size", "64KB"},
+		{"storage_ip_changed_auto_adjust", "true"},
+		{"storage_sync_file_max_delay", "86400"},
+		{"storage_sync_file_max_time", "300"},
+		{"use_trunk_file", "false"},
+		{"slot_min_size", "256"},
+		{"slot_max_size", "16MB"},
+		{"trunk_file_size", "64MB"},
+		{"trunk_create_file_advance", "false"},
+		{"trunk_create_file_time_base", "02:00"},
+		{"trunk_create_file_interval", "86400"},
+		{"trunk_create_file_space_threshold", "20G"},
+		{"trunk_init_check_occupying", "false"},
+		{"trunk_init_reload_from_binlog", "false"},
+		{"trunk_compress_binlog_min_interval", "0"},
+		{"use_storage_id", strconv.FormatBool(useStorageID(cluster))},
+		{"storage_ids_filename", v1.ConfigDir + "/" + v1.StorageIdsConfigFile},
+		{"id_type_in_filename", idTypeInFilename(cluster)},
+		{"store_slave_file_use_link", "false"},
+		{"rotate_error_log", "false"},
+		{"error_log_rotate_time", "00:00"},
+		{"rotate_error_log_size", "0"},
+		{"log_file_keep_days", "0"},
+		{"use_connection_pool", "false"},
+		{"connection_pool_max_idle_time", "3600"},
+		{"http.server_port", "8080"},
+		{"http.check_alive_interval", "30"},
+		{"http.check_alive_type", "tcp"},
+		{"http.check_alive_uri", "/status.html"},
+	}
+	if cluster.Spec.Tracker != nil {
+		c = c.apply(cluster.Spec.Tracker.Config)
+	}
+	if hosts := makeAllowHosts(cluster); len(hosts) != 0 {
+		c = c.setAll("allow_hosts", hosts)
+	}
+	return c.set("use_storage_id", strconv.FormatBool(useStorageID(cluster)))
+}
+
+// makeAllowHosts is the networks FastDFS servers accept connections from, following
+// spec.networkPolicy. Empty unless the pod network is known, pods change addresses
+func makeAllowHosts(cluster *v1.FastDFS) []string {
+	policy := cluster.Spec.NetworkPolicy
+	if policy == nil || len(policy.PodCIDRs) == 0 {
+		return nil
+	}
+	hosts := append([]string{}, policy.PodCIDRs...)
+	return append(hosts, policy.IPBlocks...)
+}
+
+// useStorageID tells whether trackers identify storage servers through storage_ids.conf,
+// enabled unless turned off, external addresses can only be advertised this way
+func useStorageID(cluster *v1.FastDFS) bool {
+	if cluster.Spec.Storage != nil && cluster.Spec.Storage.ExternalService != nil {
+		return true
+	}
+	if tracker := cluster.Spec.Tracker; tracker != nil && tracker.Config != nil && tracker.Config.UseStorageId != nil {
+		return *tracker.Config.UseStorageId
+	}
+	return true
+}
+
+// idTypeInFilename embeds storage ids into file ids when they are in use, pod ips change on rescheduling
+func idTypeInFilename(cluster *v1.FastDFS) string {
+	if useStorageID(cluster) {
+		return "id"
+	}
+	return "ip"
+}
+
+// makeStorageConf renders storage.conf of a group, parameters not in the spec keep the FastDFS defaults
+func makeStorageConf(cluster *v1.FastDFS, group string) string {
+	c := storageConf(cluster, group)
+	if cluster.Spec.Storage != nil {
+		c = c.override(cluster.Spec.Storage.ConfigOverrides)
+	}
+	c = c.forVersion(storageParametersSince, cluster.GetMajorVersion())
+	if cluster.IsHostNetwork() {
+		// the first bind_addr wins, so the node ip is the only one
+		return c.setAll("bind_addr", nil).String() + includeNodeConf
+	}
+	return c.String()
+}
+
+func storageConf(cluster *v1.FastDFS, group string) conf {
+	c := conf{
+		{"disabled", "false"},
+		{"group_name", group},
+		{"bind_addr", ""},
+		{"client_bind", "true"},
+		{"port", strconv.Itoa(cluster.GetStoragePort())},
+		{"connect_timeout", "10"},
+		{"network_timeout", "60"},
+		{"heart_beat_interval", "30"},
+		{"stat_report_interval", "60"},
+		{"base_path", v1.DataDir},
+		{"max_connections", "1024"},
+		{"buff_size", "256KB"},
+		{"accept_threads", "1"},
+		{"work_threads", "4"},
+		{"disk_rw_separated", "true"},
+		{"disk_reader_threads", "1"},
+		{"disk_writer_threads", "1"},
+		{"sync_wait_msec", "50"},
+		{"sync_interval", "0"},
+		{"sync_start_time", "00:00"},
+		{"sync_end_time", "23:59"},
+		{"write_mark_file_freq", "500"},
+		// the data volume of the pod is the only store path
+		{"store_path_count", "1"},
+		{"store_path0", v1.DataDir},
+		{"subdir_count_per_path", "256"},
+	}
+	for _, server := range cluster.GetTrackerServers() {
+		c = append(c, confEntry{key: "tracker_server", value: server})
+	}
+	c = append(c, conf{
+		{"log_level", "info"},
+		{"run_by_group", ""},
+		{"run_by_user", ""},
+		{"allow_hosts", "*"},
+		{"file_distribute_path_mode", "0"},
+		{"file_distribute_rotate_count", "100"},
+		{"fsync_after_written_bytes", "0"},
+		{"sync_log_buff_interval", "10"},
+		{"sync_binlog_buff_interval", "10"},
+		{"sync_stat_file_interval", "300"},
+		{"thread_stack_size", "512KB"},
+		{"upload_priority", "10"},
+		{"if_alias_prefix", ""},
+		{"check_file_duplicate", "0"},
+		{"file_signature_method", "hash"},
+		{"key_namespace", "FastDFS"},
+		{"keep_alive", "0"},
+		{"use_access_log", "false"},
+		{"rotate_access_log", "false"},
+		{"access_log_rotate_time", "00:00"},
+		{"rotate_error_log", "false"},
+		{"error_log_rotate_time", "00:00"},
+		{"rotate_access_log_size", "0"},
+		{"rotate_error_log_size", "0"},
+		{"log_file_keep_days", "0"},
+		{"file_sync_skip_invalid_record", "false"},
+		{"use_connection_pool", "false"},
+		{"connection_pool_max_idle_time", "3600"},
+		{"http.domain_name", ""},
+		{"http.server_port", strconv.Itoa(cluster.GetStorageHTTPPort())},
+	}...)
+	if cluster.Spec.Storage != nil {
+		c = c.apply(cluster.Spec.Storage.Config)
+	}
+	if hosts := makeAllowHosts(cluster); len(hosts) != 0 {
+		c = c.setAll("allow_hosts", hosts)
+	}
+	return c
+}
+
+// makeClientConf renders client.conf used by FastDFS tools inside the cluster
+func makeClientConf(cluster *v1.FastDFS) string {
+	c := conf{
+		{"connect_timeout", "10"},
+		{"network_timeout", "60"},
+		{"base_path", "/tmp"},
+	}
+	for _, server := range cluster.GetTrackerServers() {
+		c = append(c, confEntry{key: "tracker_server", value: server})
+	}
+	c = append(c, conf{
+		{"log_level", "info"},
+		{"use_connection_pool", "false"},
+		{"connection_pool_max_idle_time", "3600"},
+		{"load_fdfs_parameters_from_tracker", "false"},
+		{"use_storage_id", strconv.FormatBool(useStorageID(cluster))},
+		{"storage_ids_filename", storageIdsFile},
+		{"http.tracker_server_port", "80"},
+	}...)
+	return c.String() + includeHTTPConf
+}
+
+// makeHTTPConf renders http.conf, the anti-steal settings follow spec.serverAuth
+// with the secret key and token switch resolved from their secrets
+func makeHTTPConf(cluster *v1.FastDFS, secretKey string, checkToken bool) string {
+	auth := cluster.Spec.ServerAuth
+	ttl := auth.TTL
+	if ttl == 0 {
+		ttl = v1.DefaultServerAuthTTL
+	}
+	c := conf{
+		{"http.default_content_type", "application/octet-stream"},
+		{"http.mime_types_filename", "mime.types"},
+		{"http.anti_steal.check_token", strconv.FormatBool(checkToken)},
+		{"http.anti_steal.token_ttl", strconv.Itoa(int(ttl))},
+		{"http.anti_steal.secret_key", secretKey},
+		{"http.anti_steal.token_check_fail", auth.FailFallBack},
+	}
+	return c.String()
+}
+
+// makeModFastDFSConf renders mod_fastdfs.conf of the nginx module, every group
+// has its own section so a single nginx serves all the given groups
+func makeModFastDFSConf(cluster *v1.FastDFS, groups []v1.StorageGroup) string {
+	c := conf{
+		{"connect_timeout", "2"},
+		{"network_timeout", "30"},
+		{"base_path", "/tmp"},
+		{"load_fdfs_parameters_from_tracker", "true"},
+		{"storage_sync_file_max_delay", "86400"},
+		{"use_storage_id", strconv.FormatBool(useStorageID(cluster))},
+		{"storage_ids_filename", storageIdsFile},
+	}
+	for _, server := range cluster.GetTrackerServers() {
+		c = append(c, confEntry{key: "tracker_server", value: server})
+	}
+	c = append(c, conf{
+		{"storage_server_port", strconv.Itoa(cluster.GetStoragePort())},
+		{"group_name", groups[0].Name},
+		{"url_have_group_name", "true"},
+		{"store_path_count", "1"},
+		{"store_path0", v1.DataDir},
+		{"log_level", "info"},
+		{"log_filename", ""},
+		{"response_mode", "proxy"},
+		{"if_alias_prefix", ""},
+		{"flv_support", "true"},
+		{"flv_extension", "flv"},
+		{"group_count", strconv.Itoa(len(groups))},
+	}...)
+
+	var sb strings.Builder
+	sb.WriteString(c.String())
+	sb.WriteString(includeHTTPConf)
+	for _, group := range groups {
+		section := conf{
+			{"group_name", group.Name},
+			{"storage_server_port", strconv.Itoa(cluster.GetStoragePort())},
+			{"store_path_count", "1"},
+			{"store_path0", v1.DataDir},
+		}
+		sb.WriteString("\n[" + group.Name + "]\n")
+		sb.WriteString(section.String())
+	}
+	return sb.String()
+}
+
+// makeNginxConf renders the nginx.conf of the gateways, any /<group>/Mxx/ url is handed
+// to fastdfs-nginx-module which rejects unknown groups. While the anti-steal key rotates,
+// requests failing the token check are retried by the nginx holding the previous key. The
+// module answers a failed check with 400, token_check_fail is not allowed with gateways
+func makeNginxConf(listen string, previous bool) string {
+	fallback := ""
+	if previous {
+		fallback = `
+            error_page 400 = @previous;`
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(`worker_processes auto;
+error_log stderr warn;
+pid /tmp/nginx.pid;
+
+events {
+    worker_connections 1024;
+}
+
+http {
+    default_type application/octet-stream;
+    sendfile on;
+    keepalive_timeout 65;
+    access_log off;
+
+    server {
+        listen %s;
+
+        location = /healthz {
+            return 200;
+        }
+
+        location ~ ^/[^/]+/M[0-9A-Fa-f]{2}/ {
+            ngx_fastdfs_module;%s
+        }
+`, listen, fallback))
+	if previous {
+		sb.WriteString(fmt.Sprintf(`
+        location @previous {
+            proxy_pass http://127.0.0.1:%d;
+        }
+`, v1.PreviousGatewayPort))
+	}
+	sb.WriteString(`    }
+}
+`)
+	return sb.String()
+}
+
+// validateConfigOverrides checks overridden parameters against the parameters known
+// by the FastDFS release of the cluster
+func validateConfigOverrides(cluster *v1.FastDFS) error {
+	var errs []string
+	major := cluster.GetMajorVersion()
+	if tracker := cluster.Spec.Tracker; tracker != nil {
+		errs = append(errs, checkOverrides("tracker", tracker.ConfigOverrides, trackerConf(cluster),
+			managedTrackerParameters, trackerParametersSince, major)...)
+	}
+	if storage := cluster.Spec.Storage; storage != nil {
+		group := cluster.GetStorageGroups()[0].Name
+		errs = append(errs, checkOverrides("storage", storage.ConfigOverrides, storageConf(cluster, group),
+			managedStorageParameters, storageParametersSince, major)...)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid config overrides: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func checkOverrides(role string, overrides map[string]string, known conf, managed []string,
+	since map[int][]string, major int) []string {
+	var errs []string
+	for key := range overrides {
+		switch {
+		case containsString(managed, key):
+			errs = append(errs, fmt.Sprintf("%s parameter %s is managed by the operator", role, key))
+		case !known.has(key) && !isParameterIntroduced(since, key, major):
+			errs = append(errs, fmt.Sprintf("%s parameter %s is unknown to fastdfs %d", role, key, major))
+		}
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+func isParameterIntroduced(since map[int][]string, key string, major int) bool {
+	introduced := parameterIntroduction(since, key)
+	return introduced != 0 && introduced <= major
+}
+
+// parameterIntroduction is the major release introducing the parameter, 0 for the parameters of FastDFS 5
+func parameterIntroduction(since map[int][]string, key string) int {
+	for version, keys := range since {
+		if containsString(keys, key) {
+			return version
+		}
+	}
+	return 0
+}
+
+func containsString(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// assignStorageIDs reserves a block of storage ids for groups seen the first time,
+// blocks are never reused so a storage server keeps its id for its lifetime
+func assignStorageIDs(cluster *v1.FastDFS) {
+	next := int32(v1.StorageIDOffset)
+	for _, status := range cluster.Status.Groups {
+		if status.StorageIDBase >= next {
+			next = status.StorageIDBase + v1.StorageIDBlockSize
+		}
+	}
+	for _, group := range cluster.GetStorageGroups() {
+		status := cluster.GetStorageGroupStatus(group.Name)
+		if status.StorageIDBase == 0 {
+			status.StorageIDBase = next
+			next += v1.StorageIDBlockSize
+		}
+	}
+}
+
+// makeStorageIdsConf renders storage_ids.conf, every storage pod already holding an address
+// is known by its pod ip followed by its external address when exposed outside the cluster.
+// Trackers resolve host names only at startup, listing the ips restarts them through the
+// config hash once a pod comes back with another address. Pods still running during a scale
+// down keep their entries until they are removed
+func makeStorageIdsConf(cluster *v1.FastDFS, podIPs map[string]string) string {
+	var sb strings.Builder
+	for _, group := range cluster.GetStorageGroups() {
+		status := cluster.GetStorageGroupStatus(group.Name)
+		replicas := *group.Replicas
+		if status.CurrentStatefulSetReplicas > replicas {
+			replicas = status.CurrentStatefulSetReplicas
+		}
+		for ordinal := int32(0); ordinal < replicas; ordinal++ {
+			pod := cluster.GetPodName(group.Name, ordinal)
+			addresses := podIPs[pod]
+			if addresses == "" {
+				continue
+			}
+			if external := cluster.GetStorageEndpoint(pod); external != "" {
+				addresses += "," + external
+			}
+			sb.WriteString(fmt.Sprintf("%d %s %s\n", status.StorageIDBase+ordinal+1, group.Name, addresses))
+		}
+	}
+	return sb.String()
+}
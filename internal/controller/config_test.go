@@ -0,0 +1,445 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "fastdfs_operator/api/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestCluster is a defaulted cluster of the given release
+func newTestCluster(version string) *v1.FastDFS {
+	cluster := &v1.FastDFS{
+		ObjectMeta: metav1.ObjectMeta{Name: "fdfs", Namespace: "default", UID: "uid"},
+		Spec: v1.FastDFSSpec{
+			Version: version,
+			Pod:     &v1.PodOption{},
+			Storage: &v1.StorageOption{DiskSize: 10},
+		},
+	}
+	cluster.Default()
+	return cluster
+}
+
+// parseConf maps every parameter of a rendered file to its values in order, comments and
+// section headers are skipped
+func parseConf(content string) map[string][]string {
+	params := map[string][]string{}
+	for _, line := range strings.Split(content, "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		params[kv[0]] = append(params[kv[0]], kv[1])
+	}
+	return params
+}
+
+// expectParams checks the values of the given parameters, nil expects a parameter to be missing
+func expectParams(t *testing.T, content string, want map[string][]string) {
+	t.Helper()
+	params := parseConf(content)
+	for key, values := range want {
+		if got := params[key]; !reflect.DeepEqual(got, values) {
+			t.Errorf("%s = %q, want %q", key, got, values)
+		}
+	}
+}
+
+func TestMakeTrackerConf(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(cluster *v1.FastDFS)
+		want   map[string][]string
+	}{
+		{"defaults", func(cluster *v1.FastDFS) {}, map[string][]string{
+			"port":                 {"22122"},
+			"base_path":            {v1.DataDir},
+			"store_lookup":         {"2"},
+			"allow_hosts":          {"*"},
+			"use_storage_id":       {"true"},
+			"storage_ids_filename": {v1.ConfigDir + "/" + v1.StorageIdsConfigFile},
+			"id_type_in_filename":  {"id"},
+		}},
+		{"port", func(cluster *v1.FastDFS) {
+			cluster.Spec.Tracker.Port = int32Ptr(22000)
+		}, map[string][]string{"port": {"22000"}}},
+		{"typed config", func(cluster *v1.FastDFS) {
+			useTrunkFile := true
+			cluster.Spec.Tracker.Config = &v1.TrackerConfig{
+				StoreLookup:  int32Ptr(1),
+				StoreGroup:   "group2",
+				WorkThreads:  int32Ptr(8),
+				UseTrunkFile: &useTrunkFile,
+				SlotMaxSize:  "32MB",
+			}
+		}, map[string][]string{
+			"store_lookup":   {"1"},
+			"store_group":    {"group2"},
+			"work_threads":   {"8"},
+			"use_trunk_file": {"true"},
+			"slot_max_size":  {"32MB"},
+		}},
+		{"storage ids turned off", func(cluster *v1.FastDFS) {
+			cluster.Spec.Tracker.Config = &v1.TrackerConfig{UseStorageId: new(bool)}
+		}, map[string][]string{"use_storage_id": {"false"}, "id_type_in_filename": {"ip"}}},
+		{"storage ids forced by external services", func(cluster *v1.FastDFS) {
+			cluster.Spec.Tracker.Config = &v1.TrackerConfig{UseStorageId: new(bool)}
+			cluster.Spec.Storage.ExternalService = &v1.ExternalServiceOption{}
+		}, map[string][]string{"use_storage_id": {"true"}, "id_type_in_filename": {"id"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster("6.12")
+			tt.mutate(cluster)
+			expectParams(t, makeTrackerConf(cluster), tt.want)
+		})
+	}
+}
+
+func TestMakeTrackerConfIsStable(t *testing.T) {
+	cluster := newTestCluster("6.12")
+	cluster.Spec.Tracker.ConfigOverrides = map[string]string{"log_level": "debug", "work_threads": "8"}
+	if first, second := makeTrackerConf(cluster), makeTrackerConf(cluster); first != second {
+		t.Errorf("makeTrackerConf() differs between calls:\n%s\n%s", first, second)
+	}
+}
+
+func TestMakeStorageConf(t *testing.T) {
+	trackers := func(n int) []string {
+		var servers []string
+		for i := 0; i < n; i++ {
+			servers = append(servers, fmt.Sprintf("fdfs-tracker-%d.fdfs-tracker-headless.default.svc:22122", i))
+		}
+		return servers
+	}
+	tests := []struct {
+		name   string
+		group  string
+		mutate func(cluster *v1.FastDFS)
+		want   map[string][]string
+	}{
+		{"defaults", "group1", func(cluster *v1.FastDFS) {}, map[string][]string{
+			"group_name":       {"group1"},
+			"port":             {"23000"},
+			"http.server_port": {"8888"},
+			"store_path_count": {"1"},
+			"store_path0":      {v1.DataDir},
+			"base_path":        {v1.DataDir},
+			"tracker_server":   trackers(1),
+			"bind_addr":        {""},
+		}},
+		{"every tracker", "group2", func(cluster *v1.FastDFS) {
+			cluster.Spec.Tracker.Replicas = int32Ptr(3)
+		}, map[string][]string{"group_name": {"group2"}, "tracker_server": trackers(3)}},
+		{"ports", "group1", func(cluster *v1.FastDFS) {
+			cluster.Spec.Storage.Port = int32Ptr(23001)
+			cluster.Spec.Storage.HTTPPort = int32Ptr(8080)
+		}, map[string][]string{"port": {"23001"}, "http.server_port": {"8080"}}},
+		{"typed config", "group1", func(cluster *v1.FastDFS) {
+			cluster.Spec.Storage.Config = &v1.StorageConfig{BuffSize: "512KB", SyncInterval: int32Ptr(10)}
+		}, map[string][]string{"buff_size": {"512KB"}, "sync_interval": {"10"}}},
+		{"network policy allow-list", "group1", func(cluster *v1.FastDFS) {
+			cluster.Spec.NetworkPolicy = &v1.NetworkPolicyOption{
+				PodCIDRs: []string{"10.244.0.0/16"},
+				IPBlocks: []string{"192.168.0.0/24"},
+			}
+		}, map[string][]string{"allow_hosts": {"10.244.0.0/16", "192.168.0.0/24"}}},
+		{"host network binds the node ip", "group1", func(cluster *v1.FastDFS) {
+			cluster.Spec.Pod.HostNetwork = true
+		}, map[string][]string{"bind_addr": nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster("6.12")
+			tt.mutate(cluster)
+			content := makeStorageConf(cluster, tt.group)
+			expectParams(t, content, tt.want)
+			if included := strings.Contains(content, includeNodeConf); included != cluster.IsHostNetwork() {
+				t.Errorf("node.conf included = %v, want %v", included, cluster.IsHostNetwork())
+			}
+		})
+	}
+}
+
+func TestMakeClientConf(t *testing.T) {
+	cluster := newTestCluster("6.12")
+	cluster.Spec.Tracker.Replicas = int32Ptr(2)
+	content := makeClientConf(cluster)
+	expectParams(t, content, map[string][]string{
+		"tracker_server": {
+			"fdfs-tracker-0.fdfs-tracker-headless.default.svc:22122",
+			"fdfs-tracker-1.fdfs-tracker-headless.default.svc:22122",
+		},
+		"base_path":            {"/tmp"},
+		"use_storage_id":       {"true"},
+		"storage_ids_filename": {v1.StorageIdsDir + "/" + v1.StorageIdsConfigFile},
+	})
+	if !strings.HasSuffix(content, includeHTTPConf) {
+		t.Errorf("client.conf does not include http.conf:\n%s", content)
+	}
+
+	cluster.Spec.Tracker.Config = &v1.TrackerConfig{UseStorageId: new(bool)}
+	expectParams(t, makeClientConf(cluster), map[string][]string{"use_storage_id": {"false"}})
+}
+
+func TestMakeHTTPConf(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       v1.ServerAuth
+		key        string
+		checkToken bool
+		want       map[string][]string
+	}{
+		{"token checked", v1.ServerAuth{TTL: 300}, "secret", true, map[string][]string{
+			"http.anti_steal.check_token":      {"true"},
+			"http.anti_steal.token_ttl":        {"300"},
+			"http.anti_steal.secret_key":       {"secret"},
+			"http.anti_steal.token_check_fail": {""},
+		}},
+		{"token not checked", v1.ServerAuth{}, "secret", false, map[string][]string{
+			"http.anti_steal.check_token": {"false"},
+			"http.anti_steal.token_ttl":   {"600"},
+		}},
+		{"fail fallback", v1.ServerAuth{FailFallBack: "/etc/fdfs/denied.jpg"}, "secret", true, map[string][]string{
+			"http.anti_steal.token_check_fail": {"/etc/fdfs/denied.jpg"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster("6.12")
+			cluster.Spec.ServerAuth = tt.auth
+			expectParams(t, makeHTTPConf(cluster, tt.key, tt.checkToken), tt.want)
+		})
+	}
+}
+
+func TestMakeModFastDFSConf(t *testing.T) {
+	cluster := newTestCluster("6.12")
+	groups := []v1.StorageGroup{{Name: "group1"}, {Name: "group2"}}
+	content := makeModFastDFSConf(cluster, groups)
+
+	parts := strings.Split(content, "\n[")
+	if len(parts) != len(groups)+1 {
+		t.Fatalf("mod_fastdfs.conf has %d sections, want %d:\n%s", len(parts)-1, len(groups), content)
+	}
+	expectParams(t, parts[0], map[string][]string{
+		"group_name":           {"group1"},
+		"group_count":          {"2"},
+		"url_have_group_name":  {"true"},
+		"storage_server_port":  {"23000"},
+		"use_storage_id":       {"true"},
+		"storage_ids_filename": {v1.StorageIdsDir + "/" + v1.StorageIdsConfigFile},
+		"tracker_server":       {"fdfs-tracker-0.fdfs-tracker-headless.default.svc:22122"},
+	})
+	if !strings.Contains(parts[0], includeHTTPConf) {
+		t.Errorf("mod_fastdfs.conf does not include http.conf:\n%s", content)
+	}
+	for i, group := range groups {
+		section := parts[i+1]
+		if !strings.HasPrefix(section, group.Name+"]\n") {
+			t.Errorf("section %d = %q, want [%s]", i, section, group.Name)
+		}
+		expectParams(t, section, map[string][]string{
+			"group_name":  {group.Name},
+			"store_path0": {v1.DataDir},
+		})
+	}
+}
+
+func TestMakeNginxConf(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous bool
+		want     []string
+		wantNot  []string
+	}{
+		{"stable", false, []string{"listen 8080;", "ngx_fastdfs_module;"},
+			[]string{"error_page", "@previous"}},
+		{"rotating", true, []string{
+			"listen 8080;",
+			"ngx_fastdfs_module;\n            error_page 400 = @previous;",
+			fmt.Sprintf("location @previous {\n            proxy_pass http://127.0.0.1:%d;", v1.PreviousGatewayPort),
+		}, []string{"error_page 403"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := makeNginxConf("8080", tt.previous)
+			for _, want := range tt.want {
+				if !strings.Contains(content, want) {
+					t.Errorf("nginx.conf does not contain %q:\n%s", want, content)
+				}
+			}
+			for _, unwanted := range tt.wantNot {
+				if strings.Contains(content, unwanted) {
+					t.Errorf("nginx.conf contains %q:\n%s", unwanted, content)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateConfigOverrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		tracker  map[string]string
+		storage  map[string]string
+		wantErrs []string
+	}{
+		{"none", "6.12", nil, nil, nil},
+		{"known parameters", "5.11", map[string]string{"log_level": "debug"},
+			map[string]string{"sync_interval": "10"}, nil},
+		{"managed parameters", "6.12", map[string]string{"allow_hosts": "*", "port": "22000"},
+			map[string]string{"bind_addr": "0.0.0.0"}, []string{
+				"tracker parameter allow_hosts is managed by the operator",
+				"tracker parameter port is managed by the operator",
+				"storage parameter bind_addr is managed by the operator",
+			}},
+		{"unknown parameter", "6.12", map[string]string{"no_such_parameter": "1"}, nil,
+			[]string{"tracker parameter no_such_parameter is unknown to fastdfs 6"}},
+		{"parameters of a later release", "5.11", map[string]string{"response_ip_addr_size": "auto"},
+			map[string]string{"compress_binlog": "true"}, []string{
+				"tracker parameter response_ip_addr_size is unknown to fastdfs 5",
+				"storage parameter compress_binlog is unknown to fastdfs 5",
+			}},
+		{"parameters of the release", "v6.12", map[string]string{"response_ip_addr_size": "auto"},
+			map[string]string{"compress_binlog": "true"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster(tt.version)
+			cluster.Spec.Tracker.ConfigOverrides = tt.tracker
+			cluster.Spec.Storage.ConfigOverrides = tt.storage
+			err := validateConfigOverrides(cluster)
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Errorf("validateConfigOverrides() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("validateConfigOverrides() = nil, want %v", tt.wantErrs)
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("validateConfigOverrides() = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigOverridesFollowTheRelease(t *testing.T) {
+	tests := []struct {
+		version  string
+		rendered bool
+	}{
+		{"5.11", false},
+		{"6.12", true},
+		{"v6.12", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			cluster := newTestCluster(tt.version)
+			cluster.Spec.Tracker.ConfigOverrides = map[string]string{"response_ip_addr_size": "auto", "log_level": "debug"}
+			cluster.Spec.Storage.ConfigOverrides = map[string]string{"compress_binlog": "true", "log_level": "debug"}
+
+			tracker, storage := parseConf(makeTrackerConf(cluster)), parseConf(makeStorageConf(cluster, "group1"))
+			if _, ok := tracker["response_ip_addr_size"]; ok != tt.rendered {
+				t.Errorf("tracker.conf renders response_ip_addr_size = %v, want %v", ok, tt.rendered)
+			}
+			if _, ok := storage["compress_binlog"]; ok != tt.rendered {
+				t.Errorf("storage.conf renders compress_binlog = %v, want %v", ok, tt.rendered)
+			}
+			if !reflect.DeepEqual(tracker["log_level"], []string{"debug"}) ||
+				!reflect.DeepEqual(storage["log_level"], []string{"debug"}) {
+				t.Errorf("log_level = %q/%q, want debug", tracker["log_level"], storage["log_level"])
+			}
+		})
+	}
+}
+
+func TestAssignStorageIDs(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups []string
+		status []v1.StorageGroupStatus
+		want   map[string]int32
+	}{
+		{"first groups", []string{"group1", "group2"}, nil,
+			map[string]int32{"group1": v1.StorageIDOffset, "group2": v1.StorageIDOffset + v1.StorageIDBlockSize}},
+		{"assigned groups keep their blocks", []string{"group2", "group1"}, []v1.StorageGroupStatus{
+			{Name: "group1", StorageIDBase: v1.StorageIDOffset},
+			{Name: "group2", StorageIDBase: v1.StorageIDOffset + v1.StorageIDBlockSize},
+		}, map[string]int32{"group1": v1.StorageIDOffset, "group2": v1.StorageIDOffset + v1.StorageIDBlockSize}},
+		{"blocks of removed groups are not reused", []string{"group1", "group3"}, []v1.StorageGroupStatus{
+			{Name: "group1", StorageIDBase: v1.StorageIDOffset},
+			{Name: "group2", StorageIDBase: v1.StorageIDOffset + v1.StorageIDBlockSize},
+		}, map[string]int32{"group1": v1.StorageIDOffset, "group3": v1.StorageIDOffset + 2*v1.StorageIDBlockSize}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster("6.12")
+			for _, group := range tt.groups {
+				cluster.Spec.Storage.Groups = append(cluster.Spec.Storage.Groups, v1.StorageGroup{Name: group})
+			}
+			cluster.Status.Groups = tt.status
+			assignStorageIDs(cluster)
+			for group, want := range tt.want {
+				if got := cluster.GetStorageGroupStatus(group).StorageIDBase; got != want {
+					t.Errorf("storage id base of %s = %d, want %d", group, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMakeStorageIdsConf(t *testing.T) {
+	tests := []struct {
+		name      string
+		replicas  int32
+		current   int32
+		podIPs    map[string]string
+		endpoints []v1.StorageEndpoint
+		want      string
+	}{
+		{"pods holding an address", 2, 2, map[string]string{
+			"fdfs-storage-group1-0": "10.244.1.5",
+			"fdfs-storage-group1-1": "10.244.2.6",
+			"fdfs-storage-group2-0": "10.244.3.7",
+		}, nil, "100001 group1 10.244.1.5\n100002 group1 10.244.2.6\n101001 group2 10.244.3.7\n"},
+		{"no pods yet", 2, 0, nil, nil, ""},
+		{"external addresses", 1, 1, map[string]string{
+			"fdfs-storage-group1-0": "10.244.1.5",
+			"fdfs-storage-group2-0": "10.244.3.7",
+		}, []v1.StorageEndpoint{{Pod: "fdfs-storage-group1-0", Address: "203.0.113.5"}},
+			"100001 group1 10.244.1.5,203.0.113.5\n101001 group2 10.244.3.7\n"},
+		{"pods leaving during a scale down", 1, 2, map[string]string{
+			"fdfs-storage-group1-0": "10.244.1.5",
+			"fdfs-storage-group1-1": "10.244.2.6",
+			"fdfs-storage-group1-2": "10.244.2.8",
+		}, nil, "100001 group1 10.244.1.5\n100002 group1 10.244.2.6\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster("6.12")
+			cluster.Spec.Storage.Groups = []v1.StorageGroup{
+				{Name: "group1", Replicas: int32Ptr(tt.replicas)},
+				{Name: "group2", Replicas: int32Ptr(tt.replicas)},
+			}
+			assignStorageIDs(cluster)
+			for i := range cluster.Status.Groups {
+				cluster.Status.Groups[i].CurrentStatefulSetReplicas = tt.current
+			}
+			cluster.Status.Endpoints.Storage = tt.endpoints
+			if got := makeStorageIdsConf(cluster, tt.podIPs); got != tt.want {
+				t.Errorf("makeStorageIdsConf() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
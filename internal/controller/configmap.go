@@ -3,11 +3,16 @@ package controller
 import (
 	"context"
 	v1 "fastdfs_operator/api/v1"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/fearlesschenc/operator-utils/pkg/reconcile"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
@@ -17,10 +22,29 @@ func (r *FastDFSReconciler) ReconcileConfig(ctx context.Context, object metav1.O
 	cluster, _ := object.(*v1.FastDFS)
 	logr.FromContext(ctx).Info("reconcile cluster configmap")
 
+	// keep the last good configuration rather than rendering a broken one
+	if err := validateConfigOverrides(cluster); err != nil {
+		setCondition(cluster, v1.ConditionConfigApplied, metav1.ConditionFalse, "InvalidConfigOverrides", err.Error())
+		r.Eventf(cluster, corev1.EventTypeWarning, "InvalidConfigOverrides", err.Error())
+		// without any configuration yet the pods could not mount it, hold the rollout until fixed
+		if err := r.Get(ctx, cluster.GetConfigMapNamespacedName(), &corev1.ConfigMap{}); apierrors.IsNotFound(err) {
+			return reconcile.RequeueAfter(time.Second*30, nil)
+		} else if err != nil {
+			return reconcile.RequeueOnError(err)
+		}
+		return reconcile.Continue()
+	}
+
+	assignStorageIDs(cluster)
+	podIPs, err := r.getStoragePodIPs(ctx, cluster)
+	if err != nil {
+		return reconcile.RequeueOnError(err)
+	}
 	cm := makeConfigmap(cluster)
 	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
-		return r.mutateConfigmap(cluster, cm)
+		return r.mutateConfigmap(cluster, cm, podIPs)
 	}); err != nil {
+		setCondition(cluster, v1.ConditionConfigApplied, metav1.ConditionFalse, "ConfigMapSyncFailed", err.Error())
 		return reconcile.RequeueOnError(err)
 	} else {
 		switch result {
@@ -29,8 +53,40 @@ func (r *FastDFSReconciler) ReconcileConfig(ctx context.Context, object metav1.O
 			r.Eventf(cluster, corev1.EventTypeNormal, "ConfigCreated", "created fastdfs configuration")
 		case controllerutil.OperationResultUpdated:
 			logr.FromContext(ctx).Info("updated configmap")
-			r.Eventf(cluster, corev1.EventTypeNormal, "ConfigUpdated", "updated fastdfs configuration")
+			data, err := r.getConfigData(ctx, cluster)
+			if err != nil {
+				return reconcile.RequeueOnError(err)
+			}
+			r.Eventf(cluster, corev1.EventTypeNormal, "ConfigUpdated",
+				fmt.Sprintf("updated fastdfs configuration, %s", describeConfigHashes(cluster, data)))
 		}
 	}
+	setCondition(cluster, v1.ConditionConfigApplied, metav1.ConditionTrue, "ConfigMapSynced", "configuration is up to date")
 	return reconcile.Continue()
 }
+
+// getStoragePodIPs maps the storage pods already holding an address to their pod ip
+func (r *FastDFSReconciler) getStoragePodIPs(ctx context.Context, cluster *v1.FastDFS) (map[string]string, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(cluster.Namespace),
+		client.MatchingLabels(cluster.RoleMatchingLabels(v1.ServerRoleStorage))); err != nil {
+		return nil, err
+	}
+	podIPs := map[string]string{}
+	for _, pod := range pods.Items {
+		if pod.Status.PodIP != "" {
+			podIPs[pod.Name] = pod.Status.PodIP
+		}
+	}
+	return podIPs, nil
+}
+
+// describeConfigHashes lists the config hash of every role, matching the pod annotations
+func describeConfigHashes(cluster *v1.FastDFS, data map[string]string) string {
+	hashes := []string{fmt.Sprintf("tracker config hash %s", makeConfigHash(data, trackerConfigFiles()))}
+	for _, group := range cluster.GetStorageGroups() {
+		hashes = append(hashes, fmt.Sprintf("storage %s config hash %s", group.Name,
+			makeConfigHash(data, storageConfigFiles(cluster, group.Name))))
+	}
+	return strings.Join(hashes, ", ")
+}
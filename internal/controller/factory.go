@@ -4,6 +4,7 @@ import (
 	"context"
 	v1 "fastdfs_operator/api/v1"
 	"fmt"
+	"strconv"
 
 	"fastdfs_operator/pkg/utils"
 
@@ -11,12 +12,27 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func (r *FastDFSReconciler) makeStatefulSet(cluster *v1.FastDFS) *appsv1.StatefulSet {
-	nn := cluster.GetStatefulSetNamespacedName()
+// storageActiveProbe succeeds once trackers report the storage server of the pod as ACTIVE. Every
+// "Storage N:" block of fdfs_monitor is checked on its own, the pod ip has to be one of the addresses
+// of the ip_addr line, which lists several of them since FastDFS 6, and the status follows on the
+// same line or, when wrapped, on the next one
+const storageActiveProbe = `fdfs_monitor /etc/fdfs/client.conf | awk -v ip="${POD_IP}" '
+function check() { if (found && active) ok = 1; found = 0; active = 0; lines = 0 }
+/^[ \t]*Storage [0-9]+:/ { check(); next }
+/^[ \t]*ip_addr = / { lines = 2 }
+lines > 0 {
+  n = split($0, fields, /[ \t,()]+/)
+  for (i = 1; i <= n; i++) { if (fields[i] == ip) found = 1; if (fields[i] == "ACTIVE") active = 1 }
+  lines--
+}
+END { check(); exit !ok }'`
+
+func (r *FastDFSReconciler) makeStatefulSet(nn types.NamespacedName) *appsv1.StatefulSet {
 	return &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      nn.Name,
@@ -25,16 +41,44 @@ func (r *FastDFSReconciler) makeStatefulSet(cluster *v1.FastDFS) *appsv1.Statefu
 	}
 }
 
-func (r *FastDFSReconciler) makePVCStorageSize(cluster *v1.FastDFS) resource.Quantity {
-	return resource.MustParse(fmt.Sprintf(v1.StorageValueUnit, cluster.Spec.Storage.DiskSize, cluster.Spec.Storage.Unit))
+func (r *FastDFSReconciler) makePVCStorageSize(cluster *v1.FastDFS, group *v1.StorageGroup) resource.Quantity {
+	return resource.MustParse(fmt.Sprintf(v1.StorageValueUnit, group.DiskSize, cluster.Spec.Storage.Unit))
+}
+
+func (r *FastDFSReconciler) mutateTrackerStatefulSet(cluster *v1.FastDFS, sts *appsv1.StatefulSet) error {
+	if sts.ObjectMeta.CreationTimestamp.IsZero() {
+		// sts resource was not created yet, or happened any error
+		sts.ObjectMeta.Labels = cluster.RoleLabels(v1.ServerRoleTracker)
+		sts.Spec.Selector = &metav1.LabelSelector{MatchLabels: cluster.RoleMatchingLabels(v1.ServerRoleTracker)}
+		sts.Spec.ServiceName = cluster.GetHeadlessServiceName(v1.ServerRoleTracker)
+		sts.Spec.PodManagementPolicy = appsv1.ParallelPodManagement
+	}
+	sts.Spec.Replicas = makePausedReplicas(cluster, sts, cluster.GetTrackerReplicas(), cluster.GetTrackerReplicas())
+	sts.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType}
+
+	// trackers always run the expected release first
+	err := r.mutatePodTemplate(cluster, sts, v1.ServerRoleTracker, cluster.RoleLabels(v1.ServerRoleTracker),
+		cluster.Spec.Version, trackerConfigFiles())
+	if err != nil {
+		return err
+	}
+
+	// tracker only keeps its runtime state, which storage servers will report again after restart
+	sts.Spec.Template.Spec.Volumes = append(sts.Spec.Template.Spec.Volumes, corev1.Volume{
+		Name:         v1.DataVolumeName,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	})
+	r.mutateHostNetwork(cluster, v1.ServerRoleTracker, cluster.RoleLabels(v1.ServerRoleTracker), &sts.Spec.Template.Spec)
+	return controllerutil.SetControllerReference(cluster, sts, r.Scheme)
 }
 
-func (r *FastDFSReconciler) mutateStatefulSet(cluster *v1.FastDFS, sts *appsv1.StatefulSet) error {
+func (r *FastDFSReconciler) mutateStorageStatefulSet(cluster *v1.FastDFS, group *v1.StorageGroup, version string,
+	sts *appsv1.StatefulSet) error {
 	if sts.ObjectMeta.CreationTimestamp.IsZero() {
 		// sts resource was not created yet, or happened any error
-		sts.ObjectMeta.Labels = cluster.ResourceLabels()
-		sts.Spec.Selector = &metav1.LabelSelector{MatchLabels: cluster.ResourceMatchingLabels()}
-		sts.Spec.ServiceName = cluster.GetHeadlessServiceName()
+		sts.ObjectMeta.Labels = cluster.GroupLabels(group.Name)
+		sts.Spec.Selector = &metav1.LabelSelector{MatchLabels: cluster.GroupMatchingLabels(group.Name)}
+		sts.Spec.ServiceName = cluster.GetHeadlessServiceName(v1.ServerRoleStorage)
 		sts.Spec.PodManagementPolicy = appsv1.ParallelPodManagement
 
 		if sts.Spec.VolumeClaimTemplates == nil || len(sts.Spec.VolumeClaimTemplates) == 0 {
@@ -43,49 +87,96 @@ func (r *FastDFSReconciler) mutateStatefulSet(cluster *v1.FastDFS, sts *appsv1.S
 		pvc := &sts.Spec.VolumeClaimTemplates[0]
 		pvc.Name = v1.PvcName
 		pvc.Namespace = cluster.GetNamespace()
-		pvc.Labels = cluster.ResourceLabels()
+		pvc.Labels = cluster.GroupLabels(group.Name)
 		pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
 		pvc.Spec.Resources = corev1.ResourceRequirements{
 			Requests: corev1.ResourceList{
-				corev1.ResourceStorage: r.makePVCStorageSize(cluster),
+				corev1.ResourceStorage: r.makePVCStorageSize(cluster, group),
 			},
 		}
-		pvc.Spec.StorageClassName = cluster.Spec.Storage.StorageClass
+		pvc.Spec.StorageClassName = group.StorageClass
 	}
-	sts.Spec.Replicas = cluster.NextReplicas()
+	sts.Spec.Replicas = makePausedReplicas(cluster, sts, group.Replicas, cluster.NextReplicas(group))
 	sts.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType}
-	// Template
-	sts.Spec.Template.Labels = cluster.ResourceLabels()
-	annotations, err := r.makePodAnnotations(cluster)
+
+	err := r.mutatePodTemplate(cluster, sts, v1.ServerRoleStorage, cluster.GroupLabels(group.Name),
+		version, storageConfigFiles(cluster, group.Name))
+	if err != nil {
+		return err
+	}
+	sts.Spec.Template.Spec.Volumes = append(sts.Spec.Template.Spec.Volumes, makeStorageIdsVolume(cluster))
+	container := &sts.Spec.Template.Spec.Containers[0]
+	container.Env = append(container.Env, corev1.EnvVar{Name: v1.EnvGroupName, Value: group.Name})
+	container.VolumeMounts = append(container.VolumeMounts,
+		makeConfigVolumeMount(cluster.GetStorageConfigFileName(group.Name), v1.StorageConfigFile))
+	if cluster.GetGatewayMode() == v1.GatewayModeSidecar {
+		sts.Spec.Template.Spec.Containers = append(sts.Spec.Template.Spec.Containers,
+			makeGatewayContainers(cluster, version, cluster.GetModFastDFSConfigFileName(group.Name), true)...)
+	}
+	r.mutateHostNetwork(cluster, v1.ServerRoleStorage, cluster.GroupLabels(group.Name), &sts.Spec.Template.Spec)
+	return controllerutil.SetControllerReference(cluster, sts, r.Scheme)
+}
+
+// mutateHostNetwork runs the pod in the host network when spec.pod.hostNetwork is set. Host ports
+// are per node, so the servers of a role, whatever their group, spread one per node unless the
+// schedule policy is ignored or the user brings an anti-affinity. Leaving the host network
+// restores the affinity built from the pod labels and drops the host ports
+func (r *FastDFSReconciler) mutateHostNetwork(cluster *v1.FastDFS, role v1.ServerRole, labels map[string]string,
+	spec *corev1.PodSpec) {
+	wasHostNetwork := spec.HostNetwork
+	spec.HostNetwork = cluster.IsHostNetwork()
+	spec.DNSPolicy = corev1.DNSClusterFirst
+	if spec.HostNetwork {
+		// pods in the host network only resolve cluster names with this policy
+		spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
+		spec.Affinity = r.makePodAffinity(cluster, cluster.RoleMatchingLabels(role))
+	} else if wasHostNetwork {
+		spec.Affinity = r.makePodAffinity(cluster, labels)
+	}
+
+	// the api server defaults host ports to the container ports, set them to keep the template stable
+	for i := range spec.Containers {
+		for j := range spec.Containers[i].Ports {
+			spec.Containers[i].Ports[j].HostPort = 0
+			if spec.HostNetwork {
+				spec.Containers[i].Ports[j].HostPort = spec.Containers[i].Ports[j].ContainerPort
+			}
+		}
+	}
+}
+
+func (r *FastDFSReconciler) mutatePodTemplate(cluster *v1.FastDFS, sts *appsv1.StatefulSet, role v1.ServerRole,
+	labels map[string]string, version string, configFiles []string) error {
+	if sts.Annotations == nil {
+		sts.Annotations = map[string]string{}
+	}
+	sts.Annotations[v1.VersionAnnotation] = version
+
+	sts.Spec.Template.Labels = labels
+	annotations, err := r.makePodAnnotations(cluster, configFiles)
 	if err != nil {
 		return err
 	}
+	annotations[v1.VersionAnnotation] = version
 	sts.Spec.Template.Annotations = annotations
 	sts.Spec.Template.Spec.ImagePullSecrets = utils.GetReferencesFromStringSlice(cluster.Spec.Pod.ImagePullSecrets)
 	if sts.Spec.Template.Spec.Affinity == nil {
-		sts.Spec.Template.Spec.Affinity = r.makePodAffinity(cluster)
+		sts.Spec.Template.Spec.Affinity = r.makePodAffinity(cluster, labels)
 	}
 
 	sts.Spec.Template.Spec.Tolerations = cluster.Spec.Tolerations
 	sts.Spec.Template.Spec.NodeSelector = cluster.Spec.NodeSelector
 
 	// Template.Spec.Volumes
-	if sts.Spec.Template.Spec.Volumes == nil || len(sts.Spec.Template.Spec.Volumes) == 0 {
-		sts.Spec.Template.Spec.Volumes = []corev1.Volume{{}}
-	}
-	volume := &sts.Spec.Template.Spec.Volumes[0]
-	volume.Name = v1.PvcName
-
-	if volume.VolumeSource.ConfigMap == nil {
-		volume.VolumeSource.ConfigMap = &corev1.ConfigMapVolumeSource{}
-	}
-	volume.VolumeSource.ConfigMap.LocalObjectReference = corev1.LocalObjectReference{Name: cluster.GetConfigMapName()}
+	sts.Spec.Template.Spec.Volumes = []corev1.Volume{makeConfigVolume(cluster)}
 
-	sts.Spec.Template.Spec.Containers = r.makePodImage(cluster)
-	return controllerutil.SetControllerReference(cluster, sts, r.Scheme)
+	sts.Spec.Template.Spec.Containers = r.makePodImage(cluster, role, version)
+	return nil
 }
 
-func (r *FastDFSReconciler) makePodAnnotations(cluster *v1.FastDFS) (map[string]string, error) {
+// makePodAnnotations stamps the hash of the mounted config files, so only pods
+// mounting a changed file are restarted
+func (r *FastDFSReconciler) makePodAnnotations(cluster *v1.FastDFS, configFiles []string) (map[string]string, error) {
 	annotations := map[string]string{}
 	if cluster.Spec.Pod.Annotations != nil {
 		for k, v := range cluster.Spec.Pod.Annotations {
@@ -93,15 +184,16 @@ func (r *FastDFSReconciler) makePodAnnotations(cluster *v1.FastDFS) (map[string]
 		}
 	}
 
-	cm := &corev1.ConfigMap{}
-	if err := r.Client.Get(context.TODO(), cluster.GetConfigMapNamespacedName(), cm); err != nil {
+	data, err := r.getConfigData(context.TODO(), cluster)
+	if err != nil {
 		return nil, err
 	}
+	annotations[v1.ConfigHashAnnotation] = makeConfigHash(data, configFiles)
 
 	return annotations, nil
 }
 
-func (r *FastDFSReconciler) makePodAffinity(cluster *v1.FastDFS) *corev1.Affinity {
+func (r *FastDFSReconciler) makePodAffinity(cluster *v1.FastDFS, labels map[string]string) *corev1.Affinity {
 	if cluster.IgnoreSchedulePolicy() {
 		return nil
 	}
@@ -111,12 +203,12 @@ func (r *FastDFSReconciler) makePodAffinity(cluster *v1.FastDFS) *corev1.Affinit
 		affinity = cluster.Spec.Affinity.DeepCopy()
 	}
 
-	makePodAntiAffinity(cluster, affinity)
+	makePodAntiAffinity(cluster, labels, affinity)
 	makePodNodeAffinity(cluster, affinity)
 	return affinity
 }
 
-func makePodAntiAffinity(cluster *v1.FastDFS, affinity *corev1.Affinity) {
+func makePodAntiAffinity(cluster *v1.FastDFS, labels map[string]string, affinity *corev1.Affinity) {
 	if affinity.PodAntiAffinity != nil {
 		return
 	}
@@ -125,7 +217,7 @@ func makePodAntiAffinity(cluster *v1.FastDFS, affinity *corev1.Affinity) {
 		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
 			{
 				LabelSelector: &metav1.LabelSelector{
-					MatchLabels: cluster.ResourceLabels(),
+					MatchLabels: labels,
 				},
 				TopologyKey: corev1.LabelHostname,
 			},
@@ -161,22 +253,67 @@ func makePodNodeAffinity(cluster *v1.FastDFS, affinity *corev1.Affinity) {
 	affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms = terms
 }
 
-func (r *FastDFSReconciler) makePodImage(cluster *v1.FastDFS) []corev1.Container {
-	imagePullRepository := cluster.Spec.Pod.ImagePullRepository
-
+func (r *FastDFSReconciler) makePodImage(cluster *v1.FastDFS, role v1.ServerRole, version string) []corev1.Container {
 	pod := cluster.Spec.Pod
 	containers := []corev1.Container{}
 	container := corev1.Container{}
-	container.Name = pod.Image.Name
 	container.ImagePullPolicy = pod.ImagePullPolicy
-	container.Image = imagePullRepository + "/" + pod.Image.Name + ":" + pod.Image.Version
+	container.Image = cluster.GetImage(version)
 	container.Resources = pod.Resources
-	container.Command = []string{"/usr/bin/start.sh", "tracker"}
-	container.Ports = makePodPorts(pod.Image.Name)
+
+	var port int
+	var dataVolumeName string
+	switch role {
+	case v1.ServerRoleTracker:
+		port = cluster.GetTrackerPort()
+		dataVolumeName = v1.DataVolumeName
+		container.Name = v1.TrackerContainerName
+		container.Command = v1.DefaultTrackerCommand
+		if tracker := cluster.Spec.Tracker; tracker != nil {
+			if !isResourceRequirementsEmpty(tracker.Resources) {
+				container.Resources = tracker.Resources
+			}
+			if len(tracker.Command) != 0 {
+				container.Command = tracker.Command
+			}
+		}
+	case v1.ServerRoleStorage:
+		port = cluster.GetStoragePort()
+		dataVolumeName = v1.PvcName
+		container.Name = v1.StorageContainerName
+		container.Command = v1.DefaultStorageCommand
+		if !isResourceRequirementsEmpty(cluster.Spec.Storage.Resources) {
+			container.Resources = cluster.Spec.Storage.Resources
+		}
+		if len(cluster.Spec.Storage.Command) != 0 {
+			container.Command = cluster.Spec.Storage.Command
+		}
+		container.Env = append(container.Env, corev1.EnvVar{Name: v1.EnvTrackerServer, Value: cluster.GetTrackerServer()})
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:      v1.EnvPodIP,
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"}},
+		})
+		if cluster.IsHostNetwork() {
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name:      v1.EnvNodeIP,
+				ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.hostIP"}},
+			})
+			container.Command = makeNodeConfCommand(container.Command)
+		}
+	}
+	container.Env = append(container.Env, corev1.EnvVar{Name: v1.EnvPort, Value: strconv.Itoa(port)})
+	container.Ports = makePodPorts(container.Name, port)
+	if role == v1.ServerRoleStorage {
+		container.Ports = append(container.Ports, corev1.ContainerPort{
+			Name:          v1.HTTPPortName,
+			Protocol:      corev1.ProtocolTCP,
+			ContainerPort: int32(cluster.GetStorageHTTPPort()),
+		})
+	}
 	container.LivenessProbe = &corev1.Probe{
 		Handler: corev1.Handler{
 			TCPSocket: &corev1.TCPSocketAction{
-				Port: intstr.FromInt(8888),
+				Port: intstr.FromInt(port),
 			},
 		},
 		InitialDelaySeconds: 20,
@@ -185,23 +322,251 @@ func (r *FastDFSReconciler) makePodImage(cluster *v1.FastDFS) []corev1.Container
 		SuccessThreshold:    1,
 		TimeoutSeconds:      30,
 	}
+	container.ReadinessProbe = makeReadinessProbe(role, port)
 	container.VolumeMounts = []corev1.VolumeMount{
 		{
-			Name:      v1.PvcName,
+			Name:      dataVolumeName,
 			MountPath: v1.DataDir,
 		},
 	}
+	// tracker.conf includes neither client.conf nor http.conf, trackers would only restart on their changes
+	if role == v1.ServerRoleTracker {
+		container.VolumeMounts = append(container.VolumeMounts,
+			makeConfigVolumeMount(v1.TrackerConfigFile, v1.TrackerConfigFile),
+			makeConfigVolumeMount(v1.StorageIdsConfigFile, v1.StorageIdsConfigFile))
+	} else {
+		container.VolumeMounts = append(container.VolumeMounts,
+			makeConfigVolumeMount(v1.ClientConfigFile, v1.ClientConfigFile),
+			makeConfigVolumeMount(v1.HTTPConfigFile, v1.HTTPConfigFile),
+			makeStorageIdsVolumeMount())
+	}
 	containers = append(containers, container)
 	return containers
 }
 
-func makePodPorts(name string) []corev1.ContainerPort {
+// makeNodeConfCommand writes the node ip into node.conf before starting the storage server,
+// storage.conf includes it so the server binds to and advertises the node it runs on
+func makeNodeConfCommand(command []string) []string {
+	script := fmt.Sprintf(`echo "bind_addr=${%s}" > %s/%s && exec "$@"`, v1.EnvNodeIP, v1.ConfigDir, v1.NodeConfigFile)
+	return append([]string{"sh", "-c", script, v1.StorageContainerName}, command...)
+}
+
+// makeConfigVolume projects the configmap and the http config secret, since http.conf carries the anti-steal key
+func makeConfigVolume(cluster *v1.FastDFS) corev1.Volume {
+	return corev1.Volume{
+		Name: v1.ConfigVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{
+						ConfigMap: &corev1.ConfigMapProjection{
+							LocalObjectReference: corev1.LocalObjectReference{Name: cluster.GetConfigMapName()},
+						},
+					},
+					{
+						Secret: &corev1.SecretProjection{
+							LocalObjectReference: corev1.LocalObjectReference{Name: cluster.GetHTTPConfigSecretName()},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// makeStorageIdsVolume projects storage_ids.conf alone for storage servers and gateways.
+// Trackers mount it with a sub path instead, which the kubelet never updates, so they
+// only pick up changed storage addresses when the config hash restarts them
+func makeStorageIdsVolume(cluster *v1.FastDFS) corev1.Volume {
+	return corev1.Volume{
+		Name: v1.StorageIdsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: cluster.GetConfigMapName()},
+				Items:                []corev1.KeyToPath{{Key: v1.StorageIdsConfigFile, Path: v1.StorageIdsConfigFile}},
+			},
+		},
+	}
+}
+
+func makeStorageIdsVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      v1.StorageIdsVolumeName,
+		MountPath: v1.StorageIdsDir,
+		ReadOnly:  true,
+	}
+}
+
+// makeConfigVolumeMount mounts a single rendered configmap key over the file baked into the image
+func makeConfigVolumeMount(key, file string) corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      v1.ConfigVolumeName,
+		MountPath: v1.ConfigDir + "/" + file,
+		SubPath:   key,
+		ReadOnly:  true,
+	}
+}
+
+// makeReadinessProbe gates rollouts on servers actually serving, a storage server is ready
+// only when trackers report it as ACTIVE
+func makeReadinessProbe(role v1.ServerRole, port int) *corev1.Probe {
+	probe := &corev1.Probe{
+		InitialDelaySeconds: 10,
+		PeriodSeconds:       10,
+		FailureThreshold:    3,
+		SuccessThreshold:    1,
+		TimeoutSeconds:      10,
+	}
+	switch role {
+	case v1.ServerRoleStorage:
+		probe.Handler.Exec = &corev1.ExecAction{Command: []string{"sh", "-c", storageActiveProbe}}
+	default:
+		probe.Handler.TCPSocket = &corev1.TCPSocketAction{Port: intstr.FromInt(port)}
+	}
+	return probe
+}
+
+func isResourceRequirementsEmpty(resources corev1.ResourceRequirements) bool {
+	return len(resources.Limits) == 0 && len(resources.Requests) == 0
+}
+
+func makePodPorts(name string, port int) []corev1.ContainerPort {
 	return []corev1.ContainerPort{
 		{
 			Name:          name,
 			Protocol:      corev1.ProtocolTCP,
-			ContainerPort: 8888,
+			ContainerPort: int32(port),
+		},
+	}
+}
+
+func makeService(nn types.NamespacedName) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nn.Name,
+			Namespace: nn.Namespace,
+		},
+	}
+}
+
+// mutateHeadlessService gives every server of the role a stable dns name,
+// not ready addresses are published so servers find each other while bootstrapping
+func (r *FastDFSReconciler) mutateHeadlessService(cluster *v1.FastDFS, role v1.ServerRole, svc *corev1.Service) error {
+	port := cluster.GetTrackerPort()
+	if role == v1.ServerRoleStorage {
+		port = cluster.GetStoragePort()
+	}
+	svc.Labels = cluster.RoleLabels(role)
+	svc.Spec.ClusterIP = corev1.ClusterIPNone
+	svc.Spec.PublishNotReadyAddresses = true
+	svc.Spec.Selector = cluster.RoleMatchingLabels(role)
+	svc.Spec.Ports = []corev1.ServicePort{
+		{
+			Name:       string(role),
+			Protocol:   corev1.ProtocolTCP,
+			Port:       int32(port),
+			TargetPort: intstr.FromString(string(role)),
+		},
+	}
+	if role == v1.ServerRoleStorage {
+		svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{
+			Name:       v1.HTTPPortName,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       int32(cluster.GetStorageHTTPPort()),
+			TargetPort: intstr.FromString(v1.HTTPPortName),
+		})
+	}
+	return controllerutil.SetControllerReference(cluster, svc, r.Scheme)
+}
+
+// mutateTrackerService exposes trackers to applications through a single endpoint
+func (r *FastDFSReconciler) mutateTrackerService(cluster *v1.FastDFS, svc *corev1.Service) error {
+	var option *v1.ServiceOption
+	if cluster.Spec.Tracker != nil {
+		option = cluster.Spec.Tracker.Service
+	}
+	svc.Labels = cluster.RoleLabels(v1.ServerRoleTracker)
+	svc.Spec.Selector = cluster.RoleMatchingLabels(v1.ServerRoleTracker)
+	mutateServiceOption(svc, option, []corev1.ServicePort{
+		{
+			Name:       string(v1.ServerRoleTracker),
+			Protocol:   corev1.ProtocolTCP,
+			Port:       int32(cluster.GetTrackerPort()),
+			TargetPort: intstr.FromString(string(v1.ServerRoleTracker)),
 		},
+	})
+	return controllerutil.SetControllerReference(cluster, svc, r.Scheme)
+}
+
+// mutateExternalService exposes a single storage pod outside the kubernetes cluster
+func (r *FastDFSReconciler) mutateExternalService(cluster *v1.FastDFS, group string, ordinal int32,
+	svc *corev1.Service) error {
+	external := cluster.Spec.Storage.ExternalService
+	option := &v1.ServiceOption{
+		Type:                     corev1.ServiceTypeLoadBalancer,
+		Annotations:              external.Annotations,
+		LoadBalancerSourceRanges: external.LoadBalancerSourceRanges,
+		ExternalTrafficPolicy:    external.ExternalTrafficPolicy,
+	}
+	if option.ExternalTrafficPolicy == "" {
+		option.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
+	}
+
+	svc.Labels = cluster.GroupLabels(group)
+	svc.Spec.Selector = cluster.GroupMatchingLabels(group)
+	svc.Spec.Selector[appsv1.StatefulSetPodNameLabel] = cluster.GetPodName(group, ordinal)
+	mutateServiceOption(svc, option, []corev1.ServicePort{
+		{
+			Name:       string(v1.ServerRoleStorage),
+			Protocol:   corev1.ProtocolTCP,
+			Port:       int32(cluster.GetStoragePort()),
+			TargetPort: intstr.FromString(string(v1.ServerRoleStorage)),
+		},
+	})
+	return controllerutil.SetControllerReference(cluster, svc, r.Scheme)
+}
+
+// mutateServiceOption applies the user facing options of a service, node ports
+// already allocated are kept unless fixed by the options
+func mutateServiceOption(svc *corev1.Service, option *v1.ServiceOption, ports []corev1.ServicePort) {
+	if option == nil {
+		option = &v1.ServiceOption{}
+	}
+	svc.Annotations = option.Annotations
+	svc.Spec.Type = option.Type
+	if svc.Spec.Type == "" {
+		svc.Spec.Type = corev1.ServiceTypeClusterIP
+	}
+
+	exposed := svc.Spec.Type == corev1.ServiceTypeNodePort || svc.Spec.Type == corev1.ServiceTypeLoadBalancer
+	allocated := map[string]int32{}
+	for _, port := range svc.Spec.Ports {
+		allocated[port.Name] = port.NodePort
+	}
+	for i := range ports {
+		if !exposed {
+			continue
+		}
+		if nodePort, ok := option.NodePorts[ports[i].Name]; ok {
+			ports[i].NodePort = nodePort
+		} else {
+			ports[i].NodePort = allocated[ports[i].Name]
+		}
+	}
+	svc.Spec.Ports = ports
+
+	svc.Spec.LoadBalancerSourceRanges = nil
+	if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		svc.Spec.LoadBalancerSourceRanges = option.LoadBalancerSourceRanges
+	}
+	if exposed {
+		svc.Spec.ExternalTrafficPolicy = option.ExternalTrafficPolicy
+		if svc.Spec.ExternalTrafficPolicy == "" {
+			svc.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+		}
+	} else {
+		svc.Spec.ExternalTrafficPolicy = ""
+		svc.Spec.HealthCheckNodePort = 0
 	}
 }
 
@@ -215,9 +580,26 @@ func makeConfigmap(cluster *v1.FastDFS) *corev1.ConfigMap {
 	}
 }
 
-func (r *FastDFSReconciler) mutateConfigmap(cluster *v1.FastDFS, cm *corev1.ConfigMap) error {
+func (r *FastDFSReconciler) mutateConfigmap(cluster *v1.FastDFS, cm *corev1.ConfigMap, podIPs map[string]string) error {
 	cm.Labels = cluster.ResourceLabels()
 	var cd ConfigMap = make(map[string]string)
+	cd[v1.TrackerConfigFile] = makeTrackerConf(cluster)
+	for _, group := range cluster.GetStorageGroups() {
+		cd[cluster.GetStorageConfigFileName(group.Name)] = makeStorageConf(cluster, group.Name)
+	}
+	cd[v1.ClientConfigFile] = makeClientConf(cluster)
+	cd[v1.ModFastDFSConfigFile] = makeModFastDFSConf(cluster, cluster.GetStorageGroups())
+	cd[v1.StorageIdsConfigFile] = makeStorageIdsConf(cluster, podIPs)
+	if cluster.Spec.Gateway != nil {
+		cd[v1.NginxConfigFile] = makeNginxConf(strconv.Itoa(cluster.GetGatewayPort()), servesPreviousKey(cluster))
+		cd[v1.PreviousNginxConfigFile] = makeNginxConf(fmt.Sprintf("127.0.0.1:%d", v1.PreviousGatewayPort), false)
+	}
+	if cluster.GetGatewayMode() == v1.GatewayModeSidecar {
+		// sidecars read the files of their own group from the local volume
+		for _, group := range cluster.GetStorageGroups() {
+			cd[cluster.GetModFastDFSConfigFileName(group.Name)] = makeModFastDFSConf(cluster, []v1.StorageGroup{group})
+		}
+	}
 
 	cm.Data = cd
 	return controllerutil.SetControllerReference(cluster, cm, r.Scheme)
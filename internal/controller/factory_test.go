@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v1 "fastdfs_operator/api/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStorageActiveProbe(t *testing.T) {
+	for _, tool := range []string{"sh", "awk"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not available: %v", tool, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		output string
+		podIP  string
+		ready  bool
+	}{
+		{"v5 active", "fdfs_monitor_v5.txt", "10.244.2.7", true},
+		{"v5 offline", "fdfs_monitor_v5.txt", "10.244.3.9", false},
+		{"v5 wait sync", "fdfs_monitor_v5.txt", "10.244.3.10", false},
+		{"v5 ip prefix of another server", "fdfs_monitor_v5.txt", "10.244.3.1", false},
+		{"v5 unknown", "fdfs_monitor_v5.txt", "10.244.9.9", false},
+		{"v6 several addresses", "fdfs_monitor_v6.txt", "10.244.2.7", true},
+		{"v6 status wrapped to the next line", "fdfs_monitor_v6.txt", "10.244.3.9", true},
+		{"v6 offline", "fdfs_monitor_v6.txt", "10.244.3.10", false},
+		{"v6 external address of another server", "fdfs_monitor_v6.txt", "203.0.113.9", false},
+		{"no output", "", "10.244.2.7", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// fdfs_monitor prints the captured output
+			bin := t.TempDir()
+			script := "#!/bin/sh\ntrue\n"
+			if tt.output != "" {
+				output, err := filepath.Abs(filepath.Join("testdata", tt.output))
+				if err != nil {
+					t.Fatal(err)
+				}
+				script = "#!/bin/sh\ncat " + output + "\n"
+			}
+			if err := os.WriteFile(filepath.Join(bin, "fdfs_monitor"), []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := exec.Command("sh", "-c", storageActiveProbe)
+			cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"), "POD_IP="+tt.podIP)
+			out, err := cmd.CombinedOutput()
+			if ready := err == nil; ready != tt.ready {
+				t.Errorf("probe ready = %v, want %v: %v %s", ready, tt.ready, err, out)
+			}
+		})
+	}
+}
+
+func TestMutateHostNetwork(t *testing.T) {
+	userAntiAffinity := &corev1.PodAntiAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{Weight: 1}},
+	}
+	tests := []struct {
+		name             string
+		hostNetwork      bool
+		wasHostNetwork   bool
+		mutate           func(cluster *v1.FastDFS)
+		wantDNSPolicy    corev1.DNSPolicy
+		wantAntiAffinity func(cluster *v1.FastDFS) *corev1.PodAntiAffinity
+		wantHostPort     int32
+	}{
+		{"host network spreads the role", true, false, func(cluster *v1.FastDFS) {},
+			corev1.DNSClusterFirstWithHostNet, func(cluster *v1.FastDFS) *corev1.PodAntiAffinity {
+				return &corev1.PodAntiAffinity{RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: cluster.RoleMatchingLabels(v1.ServerRoleStorage)},
+					TopologyKey:   corev1.LabelHostname,
+				}}}
+			}, 23000},
+		{"host network keeps the user anti-affinity", true, false, func(cluster *v1.FastDFS) {
+			cluster.Spec.Affinity = &corev1.Affinity{PodAntiAffinity: userAntiAffinity}
+		}, corev1.DNSClusterFirstWithHostNet, func(cluster *v1.FastDFS) *corev1.PodAntiAffinity {
+			return userAntiAffinity
+		}, 23000},
+		{"host network ignoring the schedule policy", true, false, func(cluster *v1.FastDFS) {
+			cluster.Annotations = map[string]string{v1.ScheduleTypeAnnotation: v1.ScheduleTypeAnnotationValueIgnore}
+		}, corev1.DNSClusterFirstWithHostNet, nil, 23000},
+		{"leaving the host network", false, true, func(cluster *v1.FastDFS) {},
+			corev1.DNSClusterFirst, func(cluster *v1.FastDFS) *corev1.PodAntiAffinity {
+				return &corev1.PodAntiAffinity{RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: cluster.GroupLabels("group1")},
+					TopologyKey:   corev1.LabelHostname,
+				}}}
+			}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster("6.12")
+			cluster.Spec.Pod.HostNetwork = tt.hostNetwork
+			tt.mutate(cluster)
+			// the template as the previous reconcile left it
+			spec := &corev1.PodSpec{
+				HostNetwork: tt.wasHostNetwork,
+				Affinity:    &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{}},
+				Containers: []corev1.Container{{Ports: []corev1.ContainerPort{
+					{ContainerPort: 23000, HostPort: 23000},
+				}}},
+			}
+
+			r := &FastDFSReconciler{}
+			r.mutateHostNetwork(cluster, v1.ServerRoleStorage, cluster.GroupLabels("group1"), spec)
+			if spec.HostNetwork != tt.hostNetwork {
+				t.Errorf("hostNetwork = %v, want %v", spec.HostNetwork, tt.hostNetwork)
+			}
+			if spec.DNSPolicy != tt.wantDNSPolicy {
+				t.Errorf("dnsPolicy = %s, want %s", spec.DNSPolicy, tt.wantDNSPolicy)
+			}
+			var want, got *corev1.PodAntiAffinity
+			if tt.wantAntiAffinity != nil {
+				want = tt.wantAntiAffinity(cluster)
+			}
+			if spec.Affinity != nil {
+				got = spec.Affinity.PodAntiAffinity
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("podAntiAffinity = %v, want %v", got, want)
+			}
+			if port := spec.Containers[0].Ports[0].HostPort; port != tt.wantHostPort {
+				t.Errorf("hostPort = %d, want %d", port, tt.wantHostPort)
+			}
+		})
+	}
+}
@@ -26,7 +26,9 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	fastdfsv1 "fastdfs_operator/api/v1"
 	v1 "fastdfs_operator/api/v1"
@@ -35,6 +37,7 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 )
 
 // FastDFSReconciler reconciles a FastDFS object
@@ -47,15 +50,32 @@ type FastDFSReconciler struct {
 
 func (r *FastDFSReconciler) GetReconcileSteps() []reconcile.Func {
 	return reconcile.Funcs{
+		r.ReconcileSecret,
+		r.ReconcileService,
+		r.ReconcileNetworkPolicy,
 		r.ReconcileConfig,
-		r.ReconcileStatefulSet,
+		r.ReconcileHostPorts,
+		r.ReconcileTrackerStatefulSet,
+		r.ReconcileStorageStatefulSet,
+		r.ReconcileGateway,
 		r.ReconcilePersistentVolumeClaim,
+		r.ReconcileObservedGeneration,
 	}
 }
 
-//+kubebuilder:rbac:groups=fastdfs.beordie.cn,resources=fastdfs,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=fastdfs.beordie.cn,resources=fastdfs/status,verbs=get;update;patch
-//+kubebuilder:rbac:groups=fastdfs.beordie.cn,resources=fastdfs/finalizers,verbs=update
+//+kubebuilder:rbac:groups=fastdfs.beordie.cn,resources=fastdfses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=fastdfs.beordie.cn,resources=fastdfses/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=fastdfs.beordie.cn,resources=fastdfses/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=statefulsets;deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=configmaps;services;persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses;networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -75,6 +95,9 @@ func (r *FastDFSReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 	if err := r.Get(context.Background(), request.NamespacedName, cluster); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	// objects stored before the defaulting webhook, or with webhooks disabled, miss the defaults,
+	// they are filled in on the fetched copy only and never written back
+	cluster.Default()
 
 	if ShouldReconcile(cluster) {
 		result, err := reconcile.Reconcile(ctx, cluster).WithReconciler(r)
@@ -98,12 +121,29 @@ func (r *FastDFSReconciler) Eventf(cluster *v1.FastDFS, eventType, reason, messa
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *FastDFSReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// host port checks list the pods of candidate nodes only
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Pod{}, podNodeNameField,
+		func(object client.Object) []string {
+			pod := object.(*corev1.Pod)
+			if pod.Spec.NodeName == "" {
+				return nil
+			}
+			return []string{pod.Spec.NodeName}
+		}); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		WithOptions(controller.Options{MaxConcurrentReconciles: 5}).
 		For(&fastdfsv1.FastDFS{}).
 		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
 		Owns(&appsv1.StatefulSet{}).
-		Owns(&corev1.Pod{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&networkingv1.Ingress{}).
+		Owns(&networkingv1.NetworkPolicy{}).
 		Owns(&corev1.Service{}).
+		// pods carry a non controller reference to the cluster, their addresses feed storage_ids.conf
+		Watches(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{OwnerType: &fastdfsv1.FastDFS{}}).
 		Complete(r)
 }
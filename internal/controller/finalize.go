@@ -9,9 +9,9 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
-func (r *FastDFSReconciler) isPVCBeingDeleted(ctx context.Context, cluster *v1.FastDFS, replicas int32) (deleted bool, err error) {
+func (r *FastDFSReconciler) isPVCBeingDeleted(ctx context.Context, cluster *v1.FastDFS, group string, replicas int32) (deleted bool, err error) {
 	var pvcList corev1.PersistentVolumeClaimList
-	pvcList, err = r.getPVCList(ctx, cluster)
+	pvcList, err = r.getPVCList(ctx, cluster, group)
 	if err != nil {
 		r.Log.Info("Failed to get PVC list")
 		return false, err
@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"context"
+	v1 "fastdfs_operator/api/v1"
+	"fmt"
+	"strconv"
+	"time"
+
+	"fastdfs_operator/pkg/utils"
+
+	"github.com/fearlesschenc/operator-utils/pkg/reconcile"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// gatewayFetchProbe asks the local nginx for a file no storage server holds, with a token signed by
+// the current anti-steal key. fastdfs-nginx-module answers 404 for it, anything else, such as 400 for a
+// rejected token, means nginx or the module is not serving. Nothing is written into the cluster
+const gatewayFetchProbe = `group=$(sed -n 's/^group_name=//p' /etc/fdfs/mod_fastdfs.conf | head -n 1)
+id=M00/00/00/` + gatewayProbeFileName + `
+ts=$(date +%s)
+key=$(sed -n 's/^http.anti_steal.secret_key=//p' /etc/fdfs/http.conf)
+token=$(printf '%s%s%s' "$id" "$key" "$ts" | md5sum | cut -d ' ' -f 1)
+wget -S -O /dev/null "http://127.0.0.1:${GATEWAY_PORT}/${group}/${id}?token=${token}&ts=${ts}" 2>&1 |
+  grep -qE 'HTTP/[0-9.]+ 404'`
+
+// gatewayProbeFileName is a well formed file name no upload returns, it encodes the source ip
+// 127.0.0.1, the creation time 1 and an empty file. fastdfs-nginx-module neither looks for it on
+// another storage server nor waits for it to be synced, the padding stands for the extension
+const gatewayProbeFileName = "fwAAAQAAAAEAAAAAAAAAAAAAAAA" + "0000000"
+
+// ReconcileGateway deploys the download gateways, a standalone deployment or a
+// service per storage group whose pods run the gateway as a sidecar
+func (r *FastDFSReconciler) ReconcileGateway(ctx context.Context, object metav1.Object) (reconcile.Result, error) {
+	cluster, _ := object.(*v1.FastDFS)
+	r.Log.Info("reconcile cluster gateway")
+
+	expected := map[string]bool{}
+	var endpoints []string
+	switch cluster.GetGatewayMode() {
+	case v1.GatewayModeStandalone:
+		deploy := makeDeployment(cluster.GetGatewayNamespacedName())
+		expected[deploy.Name] = true
+		if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, deploy, func() error {
+			return r.mutateGatewayDeployment(cluster, deploy)
+		}); err != nil {
+			return reconcile.RequeueOnError(err)
+		} else {
+			switch result {
+			case controllerutil.OperationResultCreated:
+				r.Log.Info("created gateway deployment")
+				r.Eventf(cluster, corev1.EventTypeNormal, "DeploymentCreated", "created fastdfs gateway deployment")
+			case controllerutil.OperationResultUpdated:
+				r.Log.Info("updated gateway deployment")
+				r.Eventf(cluster, corev1.EventTypeNormal, "DeploymentUpdated", "updated fastdfs gateway deployment")
+			}
+		}
+
+		endpoint, err := r.reconcileGatewayService(ctx, cluster, cluster.GetGatewayNamespacedName(), "")
+		if err != nil {
+			return reconcile.RequeueOnError(err)
+		}
+		expected[cluster.GetGatewayName()] = true
+		endpoints = append(endpoints, endpoint)
+	case v1.GatewayModeSidecar:
+		for _, group := range cluster.GetStorageGroups() {
+			nn := cluster.GetGroupGatewayNamespacedName(group.Name)
+			endpoint, err := r.reconcileGatewayService(ctx, cluster, nn, group.Name)
+			if err != nil {
+				return reconcile.RequeueOnError(err)
+			}
+			expected[nn.Name] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	cluster.Status.Endpoints.Gateway = endpoints
+
+	if err := r.reconcileIngress(ctx, cluster); err != nil {
+		return reconcile.RequeueOnError(err)
+	}
+	if err := r.cleanupGateways(ctx, cluster, expected); err != nil {
+		return reconcile.RequeueOnError(err)
+	}
+	if meta.IsStatusConditionFalse(cluster.Status.Conditions, v1.ConditionRouteAvailable) {
+		return reconcile.RequeueAfter(routeRetryDelay(cluster, time.Now()), nil)
+	}
+	return reconcile.Continue()
+}
+
+func (r *FastDFSReconciler) reconcileGatewayService(ctx context.Context, cluster *v1.FastDFS, nn types.NamespacedName,
+	group string) (string, error) {
+	svc := makeService(nn)
+	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+		return r.mutateGatewayService(cluster, group, svc)
+	}); err != nil {
+		return "", err
+	} else if result == controllerutil.OperationResultCreated {
+		r.Log.Info("created gateway service", "service", svc.Name)
+		r.Eventf(cluster, corev1.EventTypeNormal, "ServiceCreated",
+			fmt.Sprintf("created fastdfs gateway service %s", svc.Name))
+	}
+	return fmt.Sprintf("%s.%s.svc:%d", svc.Name, svc.Namespace, svc.Spec.Ports[0].Port), nil
+}
+
+// cleanupGateways deletes the gateway deployments and services left over by a
+// removed gateway or a changed mode
+func (r *FastDFSReconciler) cleanupGateways(ctx context.Context, cluster *v1.FastDFS, expected map[string]bool) error {
+	opts := []client.ListOption{client.InNamespace(cluster.Namespace),
+		client.MatchingLabels(cluster.GatewayMatchingLabels(""))}
+
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, opts...); err != nil {
+		return err
+	}
+	services := &corev1.ServiceList{}
+	if err := r.List(ctx, services, opts...); err != nil {
+		return err
+	}
+	var objects []client.Object
+	for i := range deployments.Items {
+		objects = append(objects, &deployments.Items[i])
+	}
+	for i := range services.Items {
+		objects = append(objects, &services.Items[i])
+	}
+	for _, object := range objects {
+		if expected[object.GetName()] || !metav1.IsControlledBy(object, cluster) {
+			continue
+		}
+		if err := r.Delete(ctx, object); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		r.Log.Info("deleted gateway resource", "name", object.GetName())
+	}
+	return nil
+}
+
+func makeDeployment(nn types.NamespacedName) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nn.Name,
+			Namespace: nn.Namespace,
+		},
+	}
+}
+
+// mutateGatewayDeployment runs standalone gateways, files are proxied from the storage servers
+func (r *FastDFSReconciler) mutateGatewayDeployment(cluster *v1.FastDFS, deploy *appsv1.Deployment) error {
+	labels := cluster.GatewayLabels("")
+	if deploy.CreationTimestamp.IsZero() {
+		deploy.Spec.Selector = &metav1.LabelSelector{MatchLabels: cluster.GatewayMatchingLabels("")}
+	}
+	deploy.Labels = labels
+	deploy.Spec.Replicas = cluster.GetGatewayReplicas()
+	if cluster.Spec.Paused {
+		paused := int32(0)
+		deploy.Spec.Replicas = &paused
+	}
+
+	annotations, err := r.makePodAnnotations(cluster, gatewayConfigFiles(cluster, v1.ModFastDFSConfigFile))
+	if err != nil {
+		return err
+	}
+	template := &deploy.Spec.Template
+	template.Labels = labels
+	template.Annotations = annotations
+	template.Spec.ImagePullSecrets = utils.GetReferencesFromStringSlice(cluster.Spec.Pod.ImagePullSecrets)
+	if template.Spec.Affinity == nil {
+		template.Spec.Affinity = r.makePodAffinity(cluster, labels)
+	}
+	template.Spec.Tolerations = cluster.Spec.Tolerations
+	template.Spec.NodeSelector = cluster.Spec.NodeSelector
+	template.Spec.Volumes = []corev1.Volume{makeConfigVolume(cluster), makeStorageIdsVolume(cluster)}
+	template.Spec.Containers = makeGatewayContainers(cluster, cluster.Spec.Version, v1.ModFastDFSConfigFile, false)
+	return controllerutil.SetControllerReference(cluster, deploy, r.Scheme)
+}
+
+// mutateGatewayService exposes the standalone gateways, or the sidecar gateways of a group
+func (r *FastDFSReconciler) mutateGatewayService(cluster *v1.FastDFS, group string, svc *corev1.Service) error {
+	svc.Labels = cluster.GatewayLabels(group)
+	svc.Spec.Selector = cluster.GatewayMatchingLabels("")
+	if group != "" {
+		svc.Spec.Selector = cluster.GroupMatchingLabels(group)
+	}
+	mutateServiceOption(svc, cluster.Spec.Gateway.Service, []corev1.ServicePort{
+		{
+			Name:       v1.HTTPPortName,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       int32(cluster.GetGatewayPort()),
+			TargetPort: intstr.FromString(v1.GatewayContainerName),
+		},
+	})
+	return controllerutil.SetControllerReference(cluster, svc, r.Scheme)
+}
+
+// makeGatewayContainers builds the nginx serving downloads, sidecars read the local storage volume.
+// While the anti-steal key rotates a second standalone nginx checks tokens against the previous key
+func makeGatewayContainers(cluster *v1.FastDFS, version, modFastDFSConfigFile string, sidecar bool) []corev1.Container {
+	port := cluster.GetGatewayPort()
+	resources := cluster.Spec.Pod.Resources
+	if !isResourceRequirementsEmpty(cluster.Spec.Gateway.Resources) {
+		resources = cluster.Spec.Gateway.Resources
+	}
+
+	container := corev1.Container{
+		Name:            v1.GatewayContainerName,
+		Image:           cluster.GetGatewayImage(version),
+		ImagePullPolicy: cluster.Spec.Pod.ImagePullPolicy,
+		Command:         makeNginxCommand(v1.NginxConfigFile),
+		Resources:       resources,
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          v1.GatewayContainerName,
+				Protocol:      corev1.ProtocolTCP,
+				ContainerPort: int32(port),
+			},
+		},
+		Env: []corev1.EnvVar{
+			{Name: v1.EnvGatewayPort, Value: strconv.Itoa(port)},
+		},
+		LivenessProbe: &corev1.Probe{
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(port)},
+			},
+			InitialDelaySeconds: 10,
+			PeriodSeconds:       10,
+			FailureThreshold:    3,
+			SuccessThreshold:    1,
+			TimeoutSeconds:      5,
+		},
+		ReadinessProbe: &corev1.Probe{
+			Handler: corev1.Handler{
+				Exec: &corev1.ExecAction{Command: []string{"sh", "-c", gatewayFetchProbe}},
+			},
+			InitialDelaySeconds: 10,
+			PeriodSeconds:       10,
+			FailureThreshold:    3,
+			SuccessThreshold:    1,
+			TimeoutSeconds:      10,
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			makeConfigVolumeMount(v1.NginxConfigFile, v1.NginxConfigFile),
+			makeConfigVolumeMount(modFastDFSConfigFile, v1.ModFastDFSConfigFile),
+			makeConfigVolumeMount(v1.ClientConfigFile, v1.ClientConfigFile),
+			makeConfigVolumeMount(v1.HTTPConfigFile, v1.HTTPConfigFile),
+			makeStorageIdsVolumeMount(),
+		},
+	}
+	if sidecar {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      v1.PvcName,
+			MountPath: v1.DataDir,
+			ReadOnly:  true,
+		})
+	}
+	containers := []corev1.Container{container}
+	if sidecar || !servesPreviousKey(cluster) {
+		return containers
+	}
+
+	previous := corev1.Container{
+		Name:            v1.PreviousGatewayContainerName,
+		Image:           container.Image,
+		ImagePullPolicy: container.ImagePullPolicy,
+		Command:         makeNginxCommand(v1.PreviousNginxConfigFile),
+		Resources:       resources,
+		// the nginx only listens on loopback, which the kubelet can not reach
+		LivenessProbe: &corev1.Probe{
+			Handler: corev1.Handler{
+				Exec: &corev1.ExecAction{Command: []string{"wget", "-q", "-O", "/dev/null",
+					fmt.Sprintf("http://127.0.0.1:%d/healthz", v1.PreviousGatewayPort)}},
+			},
+			InitialDelaySeconds: 10,
+			PeriodSeconds:       10,
+			FailureThreshold:    3,
+			SuccessThreshold:    1,
+			TimeoutSeconds:      5,
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			makeConfigVolumeMount(v1.PreviousNginxConfigFile, v1.PreviousNginxConfigFile),
+			makeConfigVolumeMount(modFastDFSConfigFile, v1.ModFastDFSConfigFile),
+			makeConfigVolumeMount(v1.PreviousHTTPConfigFile, v1.HTTPConfigFile),
+			makeStorageIdsVolumeMount(),
+		},
+	}
+	return append(containers, previous)
+}
+
+// makeNginxCommand runs nginx in the foreground with a rendered configuration,
+// fastdfs-nginx-module always reads /etc/fdfs/mod_fastdfs.conf
+func makeNginxCommand(file string) []string {
+	return []string{v1.NginxBinary, "-g", "daemon off;", "-c", v1.ConfigDir + "/" + file}
+}
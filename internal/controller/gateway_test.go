@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"fastdfs_operator/pkg/fdfs/token"
+)
+
+// fdfsBase64 is the base64 alphabet FastDFS encodes file names with
+var fdfsBase64 = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_").
+	WithPadding(base64.NoPadding)
+
+func TestGatewayProbeFileName(t *testing.T) {
+	// M00/00/00/ followed by 27 encoded characters and 7 characters of extension
+	if id := "M00/00/00/" + gatewayProbeFileName; len(id) != 44 {
+		t.Fatalf("file id %s has %d characters, want 44", id, len(id))
+	}
+	raw, err := fdfsBase64.DecodeString(gatewayProbeFileName[:27])
+	if err != nil {
+		t.Fatalf("decode %s: %v", gatewayProbeFileName, err)
+	}
+	if ip := net.IP(raw[:4]).String(); ip != "127.0.0.1" {
+		t.Errorf("source ip = %s, want 127.0.0.1", ip)
+	}
+	if created := binary.BigEndian.Uint32(raw[4:8]); created != 1 {
+		t.Errorf("creation time = %d, want 1", created)
+	}
+	if size := binary.BigEndian.Uint64(raw[8:16]); size != 0 {
+		t.Errorf("file size = %d, want 0", size)
+	}
+}
+
+func TestGatewayFetchProbe(t *testing.T) {
+	for _, tool := range []string{"sh", "sed", "md5sum", "grep"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not available: %v", tool, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		status string
+		ready  bool
+	}{
+		{"file not found", "HTTP/1.1 404 Not Found", true},
+		{"token rejected", "HTTP/1.1 400 Bad Request", false},
+		{"forbidden", "HTTP/1.1 403 Forbidden", false},
+		{"served", "HTTP/1.1 200 OK", false},
+		{"bad gateway", "HTTP/1.1 502 Bad Gateway", false},
+		{"refused", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// wget records the url and prints the response status like wget -S does
+			bin := t.TempDir()
+			requested := filepath.Join(bin, "url")
+			script := "#!/bin/sh\nfor arg; do url=$arg; done\necho \"$url\" > " + requested + "\n"
+			if tt.status != "" {
+				script += "echo '  " + tt.status + "' >&2\n"
+			}
+			if err := os.WriteFile(filepath.Join(bin, "wget"), []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := exec.Command("sh", "-c", gatewayFetchProbe)
+			cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"), "GATEWAY_PORT=8080")
+			out, err := cmd.CombinedOutput()
+			if ready := err == nil; ready != tt.ready {
+				t.Errorf("probe ready = %v, want %v: %v %s", ready, tt.ready, err, out)
+			}
+
+			// without the mounted config the token is signed with an empty key
+			content, err := os.ReadFile(requested)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(strings.TrimSpace(string(content)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			id := "M00/00/00/" + gatewayProbeFileName
+			if !strings.HasSuffix(u.Path, "/"+id) || u.Host != "127.0.0.1:8080" {
+				t.Errorf("probe requested %s, want http://127.0.0.1:8080/<group>/%s", u, id)
+			}
+			ts, err := strconv.ParseInt(u.Query().Get(token.QueryTimestamp), 10, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := u.Query().Get(token.QueryToken), token.Generate(id, "", ts); got != want {
+				t.Errorf("token = %s, want %s", got, want)
+			}
+		})
+	}
+}
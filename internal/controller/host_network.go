@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"context"
+	v1 "fastdfs_operator/api/v1"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fearlesschenc/operator-utils/pkg/reconcile"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// hostPort is a port servers of a role listen on in the host network
+type hostPort struct {
+	role v1.ServerRole
+	name string
+	port int
+}
+
+// ReconcileHostPorts makes sure the servers in the host network find enough nodes with their
+// ports free before the statefulsets roll out, conflicts are reported as the PortsAvailable condition
+func (r *FastDFSReconciler) ReconcileHostPorts(ctx context.Context, object metav1.Object) (reconcile.Result, error) {
+	cluster, _ := object.(*v1.FastDFS)
+	if !cluster.IsHostNetwork() {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, v1.ConditionPortsAvailable)
+		return reconcile.Continue()
+	}
+	r.Log.Info("reconcile cluster host ports")
+
+	ports := makeHostPorts(cluster)
+	conflicts := findDuplicatePorts(ports)
+	if len(conflicts) == 0 {
+		var err error
+		if conflicts, err = r.findTakenPorts(ctx, cluster, ports); err != nil {
+			return reconcile.RequeueOnError(err)
+		}
+	}
+	if len(conflicts) != 0 {
+		message := strings.Join(conflicts, ", ")
+		setCondition(cluster, v1.ConditionPortsAvailable, metav1.ConditionFalse, "PortConflict", message)
+		r.Eventf(cluster, corev1.EventTypeWarning, "PortConflict", message)
+		return reconcile.RequeueAfter(time.Second*30, nil)
+	}
+	setCondition(cluster, v1.ConditionPortsAvailable, metav1.ConditionTrue, "PortsAvailable", "host ports are free")
+	return reconcile.Continue()
+}
+
+// makeHostPorts lists the ports every role binds on its node, sidecar gateways share
+// the node of their storage server
+func makeHostPorts(cluster *v1.FastDFS) []hostPort {
+	ports := []hostPort{
+		{v1.ServerRoleTracker, string(v1.ServerRoleTracker), cluster.GetTrackerPort()},
+		{v1.ServerRoleStorage, string(v1.ServerRoleStorage), cluster.GetStoragePort()},
+		{v1.ServerRoleStorage, v1.HTTPPortName, cluster.GetStorageHTTPPort()},
+	}
+	if cluster.GetGatewayMode() == v1.GatewayModeSidecar {
+		ports = append(ports, hostPort{v1.ServerRoleStorage, v1.GatewayContainerName, cluster.GetGatewayPort()})
+	}
+	return ports
+}
+
+// findDuplicatePorts reports ports used twice by the cluster, trackers and storage
+// servers may share a node so their ports must differ as well
+func findDuplicatePorts(ports []hostPort) []string {
+	var conflicts []string
+	seen := map[int]hostPort{}
+	for _, p := range ports {
+		if other, ok := seen[p.port]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("%s port %s and %s port %s both use %d",
+				other.role, other.name, p.role, p.name, p.port))
+			continue
+		}
+		seen[p.port] = p
+	}
+	return conflicts
+}
+
+// podNodeNameField indexes pods by the node they are bound to, so only the pods of candidate nodes are listed
+const podNodeNameField = "spec.nodeName"
+
+// findTakenPorts reports the roles having fewer schedulable nodes with their ports free than
+// replicas. A port is taken by a pod of another workload declaring it as host port, or
+// declaring it as container port in the host network
+func (r *FastDFSReconciler) findTakenPorts(ctx context.Context, cluster *v1.FastDFS, ports []hostPort) ([]string, error) {
+	list := &corev1.NodeList{}
+	if err := r.List(ctx, list, client.MatchingLabels(cluster.Spec.NodeSelector)); err != nil {
+		return nil, err
+	}
+	var nodeAffinity *corev1.NodeAffinity
+	if affinity := r.makePodAffinity(cluster, nil); affinity != nil {
+		nodeAffinity = affinity.NodeAffinity
+	}
+	var nodes []corev1.Node
+	for _, node := range list.Items {
+		if isNodeSchedulable(&node, cluster.Spec.Tolerations, nodeAffinity) {
+			nodes = append(nodes, node)
+		}
+	}
+
+	// taken maps node name and port to the pod holding it
+	taken := map[string]map[int]string{}
+	own := labels.SelectorFromSet(cluster.ResourceMatchingLabels())
+	for _, node := range nodes {
+		pods := &corev1.PodList{}
+		if err := r.List(ctx, pods, client.MatchingFields{podNodeNameField: node.Name}); err != nil {
+			return nil, err
+		}
+		taken[node.Name] = map[int]string{}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			if pod.Namespace == cluster.Namespace && own.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			for _, container := range pod.Spec.Containers {
+				for _, port := range container.Ports {
+					hostPort := port.HostPort
+					if hostPort == 0 && pod.Spec.HostNetwork {
+						hostPort = port.ContainerPort
+					}
+					if hostPort == 0 || (port.Protocol != "" && port.Protocol != corev1.ProtocolTCP) {
+						continue
+					}
+					taken[node.Name][int(hostPort)] = pod.Namespace + "/" + pod.Name
+				}
+			}
+		}
+	}
+
+	replicas := map[v1.ServerRole]int32{v1.ServerRoleTracker: *cluster.GetTrackerReplicas()}
+	for _, group := range cluster.GetStorageGroups() {
+		if group.Replicas != nil {
+			replicas[v1.ServerRoleStorage] += *group.Replicas
+		}
+	}
+
+	var conflicts []string
+	for _, role := range []v1.ServerRole{v1.ServerRoleTracker, v1.ServerRoleStorage} {
+		var free int32
+		var holders []string
+		for _, node := range nodes {
+			available := true
+			for _, p := range ports {
+				if holder, ok := taken[node.Name][p.port]; ok && p.role == role {
+					available = false
+					holders = append(holders, fmt.Sprintf("%d on %s by %s", p.port, node.Name, holder))
+				}
+			}
+			if available {
+				free++
+			}
+		}
+		// too few nodes alone is left to the scheduler, only taken ports hold the rollout
+		if free < replicas[role] && len(holders) != 0 {
+			sort.Strings(holders)
+			conflicts = append(conflicts, fmt.Sprintf("%d of %d %s servers fit on nodes with free ports, taken %s",
+				free, replicas[role], role, strings.Join(holders, ", ")))
+		}
+	}
+	return conflicts, nil
+}
+
+// isNodeSchedulable tells whether the servers may be scheduled on the node, the node must accept
+// new pods, its scheduling taints must be tolerated and it must match the required node affinity
+func isNodeSchedulable(node *corev1.Node, tolerations []corev1.Toleration, nodeAffinity *corev1.NodeAffinity) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range tolerations {
+			if tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	if nodeAffinity == nil || nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	// terms are ORed, the requirements of a term ANDed
+	for _, term := range nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if matchNodeSelectorTerm(node, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeSelectorOperators maps node selector operators to their label selector counterparts
+var nodeSelectorOperators = map[corev1.NodeSelectorOperator]selection.Operator{
+	corev1.NodeSelectorOpIn:           selection.In,
+	corev1.NodeSelectorOpNotIn:        selection.NotIn,
+	corev1.NodeSelectorOpExists:       selection.Exists,
+	corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	corev1.NodeSelectorOpGt:           selection.GreaterThan,
+	corev1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+// matchNodeSelectorTerm matches the node labels against the expressions and the node name
+// against the fields of the term, an empty term matches no node
+func matchNodeSelectorTerm(node *corev1.Node, term corev1.NodeSelectorTerm) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	for _, requirement := range term.MatchExpressions {
+		operator, ok := nodeSelectorOperators[requirement.Operator]
+		if !ok {
+			return false
+		}
+		r, err := labels.NewRequirement(requirement.Key, operator, requirement.Values)
+		if err != nil || !r.Matches(labels.Set(node.Labels)) {
+			return false
+		}
+	}
+	// metadata.name is the only field supported by the scheduler
+	for _, requirement := range term.MatchFields {
+		if requirement.Key != "metadata.name" {
+			return false
+		}
+		named := containsString(requirement.Values, node.Name)
+		switch requirement.Operator {
+		case corev1.NodeSelectorOpIn:
+			if !named {
+				return false
+			}
+		case corev1.NodeSelectorOpNotIn:
+			if named {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "fastdfs_operator/api/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// nodeClient serves nodes and the pods bound to them
+type nodeClient struct {
+	client.Client
+	nodes []corev1.Node
+	pods  []corev1.Pod
+}
+
+func (c *nodeClient) List(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	options := &client.ListOptions{}
+	options.ApplyOptions(opts)
+	switch list := list.(type) {
+	case *corev1.NodeList:
+		for _, node := range c.nodes {
+			if options.LabelSelector == nil || options.LabelSelector.Matches(labels.Set(node.Labels)) {
+				list.Items = append(list.Items, node)
+			}
+		}
+	case *corev1.PodList:
+		nodeName, _ := options.FieldSelector.RequiresExactMatch(podNodeNameField)
+		for _, pod := range c.pods {
+			if pod.Spec.NodeName == nodeName {
+				list.Items = append(list.Items, pod)
+			}
+		}
+	}
+	return nil
+}
+
+func newTestNode(name string, labels map[string]string, taints ...corev1.Taint) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       corev1.NodeSpec{Taints: taints},
+	}
+}
+
+// newHostPortPod is a pod of another workload binding the port on the node
+func newHostPortPod(name, node string, port int32) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "other"},
+		Spec: corev1.PodSpec{
+			NodeName:   node,
+			Containers: []corev1.Container{{Ports: []corev1.ContainerPort{{ContainerPort: port, HostPort: port}}}},
+		},
+	}
+}
+
+func TestMakeHostPorts(t *testing.T) {
+	cluster := newTestCluster("6.12")
+	want := []hostPort{
+		{v1.ServerRoleTracker, string(v1.ServerRoleTracker), cluster.GetTrackerPort()},
+		{v1.ServerRoleStorage, string(v1.ServerRoleStorage), cluster.GetStoragePort()},
+		{v1.ServerRoleStorage, v1.HTTPPortName, cluster.GetStorageHTTPPort()},
+	}
+	if got := makeHostPorts(cluster); !reflect.DeepEqual(got, want) {
+		t.Errorf("makeHostPorts() = %v, want %v", got, want)
+	}
+
+	cluster.Spec.Gateway = &v1.GatewayOption{Mode: v1.GatewayModeSidecar, Port: int32Ptr(8080)}
+	want = append(want, hostPort{v1.ServerRoleStorage, v1.GatewayContainerName, 8080})
+	if got := makeHostPorts(cluster); !reflect.DeepEqual(got, want) {
+		t.Errorf("makeHostPorts() with sidecars = %v, want %v", got, want)
+	}
+}
+
+func TestFindDuplicatePorts(t *testing.T) {
+	tests := []struct {
+		name  string
+		ports []hostPort
+		want  []string
+	}{
+		{"distinct", []hostPort{
+			{v1.ServerRoleTracker, "tracker", 22122},
+			{v1.ServerRoleStorage, "storage", 23000},
+		}, nil},
+		{"tracker and storage", []hostPort{
+			{v1.ServerRoleTracker, "tracker", 22122},
+			{v1.ServerRoleStorage, "storage", 22122},
+		}, []string{"tracker port tracker and storage port storage both use 22122"}},
+		{"storage and sidecar", []hostPort{
+			{v1.ServerRoleStorage, "http", 8080},
+			{v1.ServerRoleStorage, "gateway", 8080},
+		}, []string{"storage port http and storage port gateway both use 8080"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findDuplicatePorts(tt.ports); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findDuplicatePorts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsNodeSchedulable(t *testing.T) {
+	dedicated := corev1.Taint{Key: "dedicated", Value: "fastdfs", Effect: corev1.TaintEffectNoSchedule}
+	zoneAffinity := &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+		NodeSelectorTerms: []corev1.NodeSelectorTerm{
+			{MatchExpressions: []corev1.NodeSelectorRequirement{
+				{Key: v1.TopologyKey, Operator: corev1.NodeSelectorOpIn, Values: []string{"zone-a"}},
+			}},
+			{MatchFields: []corev1.NodeSelectorRequirement{
+				{Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: []string{"node-pinned"}},
+			}},
+		},
+	}}
+	tests := []struct {
+		name         string
+		node         corev1.Node
+		tolerations  []corev1.Toleration
+		nodeAffinity *corev1.NodeAffinity
+		want         bool
+	}{
+		{"plain node", newTestNode("node", nil), nil, nil, true},
+		{"cordoned", func() corev1.Node {
+			node := newTestNode("node", nil)
+			node.Spec.Unschedulable = true
+			return node
+		}(), nil, nil, false},
+		{"untolerated taint", newTestNode("node", nil, dedicated), nil, nil, false},
+		{"tolerated taint", newTestNode("node", nil, dedicated), []corev1.Toleration{
+			{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "fastdfs", Effect: corev1.TaintEffectNoSchedule},
+		}, nil, true},
+		{"toleration of another value", newTestNode("node", nil, dedicated), []corev1.Toleration{
+			{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "other"},
+		}, nil, false},
+		{"untolerated no execute", newTestNode("node", nil,
+			corev1.Taint{Key: "node.kubernetes.io/unreachable", Effect: corev1.TaintEffectNoExecute}), nil, nil, false},
+		{"preferred taint", newTestNode("node", nil,
+			corev1.Taint{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule}), nil, nil, true},
+		{"zone matching the affinity", newTestNode("node", map[string]string{v1.TopologyKey: "zone-a"}),
+			nil, zoneAffinity, true},
+		{"zone outside the affinity", newTestNode("node", map[string]string{v1.TopologyKey: "zone-b"}),
+			nil, zoneAffinity, false},
+		{"node named by the affinity", newTestNode("node-pinned", nil), nil, zoneAffinity, true},
+		{"affinity without required terms", newTestNode("node", nil), nil, &corev1.NodeAffinity{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNodeSchedulable(&tt.node, tt.tolerations, tt.nodeAffinity); got != tt.want {
+				t.Errorf("isNodeSchedulable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindTakenPorts(t *testing.T) {
+	dedicated := corev1.Taint{Key: "dedicated", Value: "fastdfs", Effect: corev1.TaintEffectNoSchedule}
+	tests := []struct {
+		name   string
+		mutate func(cluster *v1.FastDFS)
+		nodes  []corev1.Node
+		pods   []corev1.Pod
+		want   int
+	}{
+		{"free nodes", func(cluster *v1.FastDFS) {}, []corev1.Node{
+			newTestNode("node-1", nil), newTestNode("node-2", nil),
+		}, nil, 0},
+		{"port taken on the only node", func(cluster *v1.FastDFS) {}, []corev1.Node{
+			newTestNode("node-1", nil),
+		}, []corev1.Pod{newHostPortPod("web", "node-1", 22122)}, 1},
+		{"port taken but another node is free", func(cluster *v1.FastDFS) {}, []corev1.Node{
+			newTestNode("node-1", nil), newTestNode("node-2", nil),
+		}, []corev1.Pod{newHostPortPod("web", "node-1", 22122)}, 0},
+		{"the free node is tainted", func(cluster *v1.FastDFS) {}, []corev1.Node{
+			newTestNode("node-1", nil), newTestNode("node-2", nil, dedicated),
+		}, []corev1.Pod{newHostPortPod("web", "node-1", 22122)}, 1},
+		{"the free node is tolerated", func(cluster *v1.FastDFS) {
+			cluster.Spec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}
+		}, []corev1.Node{
+			newTestNode("node-1", nil), newTestNode("node-2", nil, dedicated),
+		}, []corev1.Pod{newHostPortPod("web", "node-1", 22122)}, 0},
+		{"the free node is outside the zones", func(cluster *v1.FastDFS) {
+			cluster.Spec.AvailableZones = []string{"zone-a"}
+		}, []corev1.Node{
+			newTestNode("node-1", map[string]string{v1.TopologyKey: "zone-a"}),
+			newTestNode("node-2", map[string]string{v1.TopologyKey: "zone-b"}),
+		}, []corev1.Pod{newHostPortPod("web", "node-1", 22122)}, 1},
+		{"zones ignored with the schedule policy", func(cluster *v1.FastDFS) {
+			cluster.Spec.AvailableZones = []string{"zone-a"}
+			cluster.Annotations = map[string]string{v1.ScheduleTypeAnnotation: v1.ScheduleTypeAnnotationValueIgnore}
+		}, []corev1.Node{
+			newTestNode("node-1", map[string]string{v1.TopologyKey: "zone-a"}),
+			newTestNode("node-2", map[string]string{v1.TopologyKey: "zone-b"}),
+		}, []corev1.Pod{newHostPortPod("web", "node-1", 22122)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster("6.12")
+			cluster.Spec.Pod.HostNetwork = true
+			tt.mutate(cluster)
+			r := &FastDFSReconciler{Client: &nodeClient{nodes: tt.nodes, pods: tt.pods}}
+			conflicts, err := r.findTakenPorts(context.TODO(), cluster, makeHostPorts(cluster))
+			if err != nil {
+				t.Fatalf("findTakenPorts() error = %v", err)
+			}
+			if len(conflicts) != tt.want {
+				t.Errorf("findTakenPorts() = %q, want %d conflicts", conflicts, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"context"
+	v1 "fastdfs_operator/api/v1"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// the Gateway API is optional in the kubernetes cluster, its resources are handled unstructured
+var (
+	httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+	gatewayGVK   = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "Gateway"}
+)
+
+const gatewayAPIUnavailableMessage = "HTTPRoute is not served by the kubernetes cluster, install the Gateway API to route downloads"
+
+// routeRetryDelay backs off while the Gateway API is missing, the delay doubles with the
+// time the route has been unavailable, from 30 seconds up to 10 minutes
+func routeRetryDelay(cluster *v1.FastDFS, now time.Time) time.Duration {
+	delay := 30 * time.Second
+	if condition := meta.FindStatusCondition(cluster.Status.Conditions, v1.ConditionRouteAvailable); condition != nil {
+		if unavailable := now.Sub(condition.LastTransitionTime.Time); unavailable > delay {
+			delay = unavailable
+		}
+	}
+	if delay > 10*time.Minute {
+		delay = 10 * time.Minute
+	}
+	return delay
+}
+
+// reconcileIngress routes downloads from outside the kubernetes cluster with the kind of
+// route asked for, the route of the other kind is removed
+func (r *FastDFSReconciler) reconcileIngress(ctx context.Context, cluster *v1.FastDFS) error {
+	var addresses []string
+	var err error
+	kind := cluster.GetIngressKind()
+
+	ing := makeIngress(cluster.GetGatewayNamespacedName())
+	if kind == v1.IngressKindIngress {
+		if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, ing, func() error {
+			return r.mutateIngress(cluster, ing)
+		}); err != nil {
+			return err
+		} else if result == controllerutil.OperationResultCreated {
+			r.Log.Info("created gateway ingress")
+			r.Eventf(cluster, corev1.EventTypeNormal, "IngressCreated", "created fastdfs gateway ingress")
+		}
+		for _, lb := range ing.Status.LoadBalancer.Ingress {
+			if lb.IP != "" {
+				addresses = append(addresses, lb.IP)
+			} else if lb.Hostname != "" {
+				addresses = append(addresses, lb.Hostname)
+			}
+		}
+	} else if err := r.deleteOwned(ctx, cluster, ing); err != nil {
+		return err
+	}
+
+	route := makeHTTPRoute(cluster.GetGatewayNamespacedName())
+	if kind == v1.IngressKindHTTPRoute {
+		if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, route, func() error {
+			return r.mutateHTTPRoute(cluster, route)
+		}); meta.IsNoMatchError(err) {
+			// warn once, the condition tells the rest of the time
+			if !meta.IsStatusConditionFalse(cluster.Status.Conditions, v1.ConditionRouteAvailable) {
+				r.Eventf(cluster, corev1.EventTypeWarning, "GatewayAPIUnavailable", gatewayAPIUnavailableMessage)
+			}
+			setCondition(cluster, v1.ConditionRouteAvailable, metav1.ConditionFalse, "GatewayAPIUnavailable",
+				gatewayAPIUnavailableMessage)
+		} else if err != nil {
+			return err
+		} else {
+			if result == controllerutil.OperationResultCreated {
+				r.Log.Info("created gateway http route")
+				r.Eventf(cluster, corev1.EventTypeNormal, "HTTPRouteCreated", "created fastdfs gateway http route")
+			}
+			setCondition(cluster, v1.ConditionRouteAvailable, metav1.ConditionTrue, "HTTPRouteSynced",
+				"downloads are routed by the http route")
+		}
+		if addresses, err = r.getParentGatewayAddresses(ctx, cluster); err != nil {
+			return err
+		}
+	} else {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, v1.ConditionRouteAvailable)
+		if err := r.deleteOwned(ctx, cluster, route); err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+
+	cluster.Status.Endpoints.GatewayExternal = addresses
+	return nil
+}
+
+// deleteOwned deletes the object when it exists and is owned by the cluster
+func (r *FastDFSReconciler) deleteOwned(ctx context.Context, cluster *v1.FastDFS, object client.Object) error {
+	if err := r.Get(ctx, client.ObjectKeyFromObject(object), object); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(object, cluster) {
+		return nil
+	}
+	if err := r.Delete(ctx, object); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	r.Log.Info("deleted gateway route", "kind", object.GetObjectKind().GroupVersionKind().Kind, "name", object.GetName())
+	return nil
+}
+
+func makeIngress(nn types.NamespacedName) *networkingv1.Ingress {
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nn.Name,
+			Namespace: nn.Namespace,
+		},
+	}
+}
+
+func makeHTTPRoute(nn types.NamespacedName) *unstructured.Unstructured {
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(httpRouteGVK)
+	route.SetNamespace(nn.Namespace)
+	route.SetName(nn.Name)
+	return route
+}
+
+// makeIngressAnnotations passes the user annotations through, cert-manager issues
+// the certificate of the hostnames when an issuer is set
+func makeIngressAnnotations(option *v1.GatewayIngressOption) map[string]string {
+	annotations := map[string]string{}
+	for k, v := range option.Annotations {
+		annotations[k] = v
+	}
+	if option.Issuer != nil {
+		key := v1.IssuerAnnotation
+		if option.Issuer.Kind == "ClusterIssuer" {
+			key = v1.ClusterIssuerAnnotation
+		}
+		annotations[key] = option.Issuer.Name
+	}
+	return annotations
+}
+
+// getGatewayBackend is the service serving the files of a group
+func getGatewayBackend(cluster *v1.FastDFS, group string) string {
+	if cluster.GetGatewayMode() == v1.GatewayModeSidecar {
+		return cluster.GetGroupGatewayName(group)
+	}
+	return cluster.GetGatewayName()
+}
+
+// mutateIngress routes /<group>/ of every hostname to the gateways serving the group
+func (r *FastDFSReconciler) mutateIngress(cluster *v1.FastDFS, ing *networkingv1.Ingress) error {
+	option := cluster.Spec.Gateway.Ingress
+	ing.Labels = cluster.GatewayLabels("")
+	ing.Annotations = makeIngressAnnotations(option)
+	ing.Spec.IngressClassName = option.ClassName
+
+	pathType := networkingv1.PathTypePrefix
+	paths := []networkingv1.HTTPIngressPath{}
+	for _, group := range cluster.GetIngressGroups() {
+		paths = append(paths, networkingv1.HTTPIngressPath{
+			Path:     "/" + group + "/",
+			PathType: &pathType,
+			Backend: networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: getGatewayBackend(cluster, group),
+					Port: networkingv1.ServiceBackendPort{Number: int32(cluster.GetGatewayPort())},
+				},
+			},
+		})
+	}
+	hosts := option.Hostnames
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	ing.Spec.Rules = nil
+	for _, host := range hosts {
+		ing.Spec.Rules = append(ing.Spec.Rules, networkingv1.IngressRule{
+			Host: host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths},
+			},
+		})
+	}
+
+	ing.Spec.TLS = nil
+	if secret := cluster.GetGatewayTLSSecretName(); secret != "" {
+		ing.Spec.TLS = []networkingv1.IngressTLS{{Hosts: option.Hostnames, SecretName: secret}}
+	}
+	return controllerutil.SetControllerReference(cluster, ing, r.Scheme)
+}
+
+// mutateHTTPRoute routes /<group>/ of every hostname to the gateways serving the group
+func (r *FastDFSReconciler) mutateHTTPRoute(cluster *v1.FastDFS, route *unstructured.Unstructured) error {
+	option := cluster.Spec.Gateway.Ingress
+	route.SetLabels(cluster.GatewayLabels(""))
+	route.SetAnnotations(makeIngressAnnotations(option))
+
+	parentRefs := []interface{}{}
+	for _, ref := range option.ParentRefs {
+		// defaults of the Gateway API are set so unchanged routes are not updated
+		parentRef := map[string]interface{}{"group": gatewayGVK.Group, "kind": gatewayGVK.Kind, "name": ref.Name}
+		if ref.Namespace != "" {
+			parentRef["namespace"] = ref.Namespace
+		}
+		if ref.SectionName != "" {
+			parentRef["sectionName"] = ref.SectionName
+		}
+		parentRefs = append(parentRefs, parentRef)
+	}
+	hostnames := []interface{}{}
+	for _, host := range option.Hostnames {
+		hostnames = append(hostnames, host)
+	}
+	rules := []interface{}{}
+	for _, group := range cluster.GetIngressGroups() {
+		rules = append(rules, map[string]interface{}{
+			"matches": []interface{}{
+				map[string]interface{}{
+					"path": map[string]interface{}{"type": "PathPrefix", "value": "/" + group + "/"},
+				},
+			},
+			"backendRefs": []interface{}{
+				map[string]interface{}{
+					"group":  "",
+					"kind":   "Service",
+					"name":   getGatewayBackend(cluster, group),
+					"port":   int64(cluster.GetGatewayPort()),
+					"weight": int64(1),
+				},
+			},
+		})
+	}
+
+	spec := map[string]interface{}{
+		"parentRefs": parentRefs,
+		"rules":      rules,
+	}
+	if len(hostnames) != 0 {
+		spec["hostnames"] = hostnames
+	}
+	if err := unstructured.SetNestedField(route.Object, spec, "spec"); err != nil {
+		return err
+	}
+	return controllerutil.SetControllerReference(cluster, route, r.Scheme)
+}
+
+// getParentGatewayAddresses collects the addresses of the gateways the route attaches to
+func (r *FastDFSReconciler) getParentGatewayAddresses(ctx context.Context, cluster *v1.FastDFS) ([]string, error) {
+	var addresses []string
+	for _, ref := range cluster.Spec.Gateway.Ingress.ParentRefs {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = cluster.Namespace
+		}
+		gateway := &unstructured.Unstructured{}
+		gateway.SetGroupVersionKind(gatewayGVK)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, gateway); err != nil {
+			if client.IgnoreNotFound(err) == nil || meta.IsNoMatchError(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get parent gateway %s/%s: %w", namespace, ref.Name, err)
+		}
+		statusAddresses, _, _ := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+		for _, address := range statusAddresses {
+			if value, ok := address.(map[string]interface{})["value"].(string); ok && value != "" {
+				addresses = append(addresses, value)
+			}
+		}
+	}
+	return addresses, nil
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "fastdfs_operator/api/v1"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// noGatewayAPIClient serves a kubernetes cluster without the Gateway API, nothing else exists
+type noGatewayAPIClient struct {
+	client.Client
+}
+
+func (c *noGatewayAPIClient) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return &meta.NoKindMatchError{GroupKind: u.GroupVersionKind().GroupKind()}
+	}
+	return newNotFound(key.Name)
+}
+
+func TestReconcileIngressWithoutGatewayAPI(t *testing.T) {
+	cluster := newTestCluster("6.12")
+	cluster.Spec.Gateway = &v1.GatewayOption{Ingress: &v1.GatewayIngressOption{Kind: v1.IngressKindHTTPRoute}}
+	recorder := record.NewFakeRecorder(10)
+	r := &FastDFSReconciler{Client: &noGatewayAPIClient{}, Recorder: recorder, Log: logr.Discard()}
+
+	for i := 0; i < 3; i++ {
+		if err := r.reconcileIngress(context.TODO(), cluster); err != nil {
+			t.Fatalf("reconcileIngress() error = %v", err)
+		}
+	}
+	if !meta.IsStatusConditionFalse(cluster.Status.Conditions, v1.ConditionRouteAvailable) {
+		t.Errorf("conditions = %v, want %s false", cluster.Status.Conditions, v1.ConditionRouteAvailable)
+	}
+	if events := len(recorder.Events); events != 1 {
+		t.Errorf("recorded %d events, want 1", events)
+	}
+
+	// routes are no longer asked for
+	cluster.Spec.Gateway.Ingress = nil
+	if err := r.reconcileIngress(context.TODO(), cluster); err != nil {
+		t.Fatalf("reconcileIngress() error = %v", err)
+	}
+	if condition := meta.FindStatusCondition(cluster.Status.Conditions, v1.ConditionRouteAvailable); condition != nil {
+		t.Errorf("condition %s = %v, want none", v1.ConditionRouteAvailable, condition)
+	}
+}
+
+func TestRouteRetryDelay(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		unavailable time.Duration
+		want        time.Duration
+	}{
+		{"just unavailable", 0, 30 * time.Second},
+		{"unavailable for a while", 2 * time.Minute, 2 * time.Minute},
+		{"unavailable for long", time.Hour, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster("6.12")
+			cluster.Status.Conditions = []metav1.Condition{{
+				Type:               v1.ConditionRouteAvailable,
+				Status:             metav1.ConditionFalse,
+				LastTransitionTime: metav1.NewTime(now.Add(-tt.unavailable)),
+			}}
+			if got := routeRetryDelay(cluster, now); got != tt.want {
+				t.Errorf("routeRetryDelay() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"context"
+	v1 "fastdfs_operator/api/v1"
+	"fmt"
+
+	"github.com/fearlesschenc/operator-utils/pkg/reconcile"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ReconcileNetworkPolicy guards trackers, every storage group and the standalone gateways
+// with a network policy each, policies are removed once spec.networkPolicy is cleared
+func (r *FastDFSReconciler) ReconcileNetworkPolicy(ctx context.Context, object metav1.Object) (reconcile.Result, error) {
+	cluster, _ := object.(*v1.FastDFS)
+	r.Log.Info("reconcile cluster network policy")
+
+	expected := map[string]bool{}
+	if cluster.Spec.NetworkPolicy != nil {
+		type policy struct {
+			nn     types.NamespacedName
+			mutate func(*networkingv1.NetworkPolicy)
+		}
+		policies := []policy{{
+			nn: cluster.GetNetworkPolicyNamespacedName(v1.ServerRoleTracker, ""),
+			mutate: func(np *networkingv1.NetworkPolicy) {
+				mutateTrackerNetworkPolicy(cluster, np)
+			},
+		}}
+		for _, group := range cluster.GetStorageGroups() {
+			group := group.Name
+			policies = append(policies, policy{
+				nn: cluster.GetNetworkPolicyNamespacedName(v1.ServerRoleStorage, group),
+				mutate: func(np *networkingv1.NetworkPolicy) {
+					mutateStorageNetworkPolicy(cluster, group, np)
+				},
+			})
+		}
+		if cluster.GetGatewayMode() == v1.GatewayModeStandalone {
+			policies = append(policies, policy{
+				nn: cluster.GetNetworkPolicyNamespacedName(v1.ServerRoleGateway, ""),
+				mutate: func(np *networkingv1.NetworkPolicy) {
+					mutateGatewayNetworkPolicy(cluster, np)
+				},
+			})
+		}
+
+		for _, p := range policies {
+			np := makeNetworkPolicy(p.nn)
+			expected[np.Name] = true
+			if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, np, func() error {
+				p.mutate(np)
+				return controllerutil.SetControllerReference(cluster, np, r.Scheme)
+			}); err != nil {
+				return reconcile.RequeueOnError(err)
+			} else if result == controllerutil.OperationResultCreated {
+				r.Log.Info("created network policy", "policy", np.Name)
+				r.Eventf(cluster, corev1.EventTypeNormal, "NetworkPolicyCreated",
+					fmt.Sprintf("created fastdfs network policy %s", np.Name))
+			}
+		}
+	}
+
+	policies := &networkingv1.NetworkPolicyList{}
+	if err := r.List(ctx, policies, client.InNamespace(cluster.Namespace),
+		client.MatchingLabels(cluster.ResourceMatchingLabels())); err != nil {
+		return reconcile.RequeueOnError(err)
+	}
+	for i := range policies.Items {
+		np := &policies.Items[i]
+		if expected[np.Name] || !metav1.IsControlledBy(np, cluster) {
+			continue
+		}
+		if err := r.Delete(ctx, np); client.IgnoreNotFound(err) != nil {
+			return reconcile.RequeueOnError(err)
+		}
+		r.Log.Info("deleted network policy", "policy", np.Name)
+	}
+	return reconcile.Continue()
+}
+
+func makeNetworkPolicy(nn types.NamespacedName) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nn.Name,
+			Namespace: nn.Namespace,
+		},
+	}
+}
+
+// mutateTrackerNetworkPolicy lets trackers reach each other, and storage servers,
+// gateways and clients reach trackers
+func mutateTrackerNetworkPolicy(cluster *v1.FastDFS, np *networkingv1.NetworkPolicy) {
+	np.Labels = cluster.RoleLabels(v1.ServerRoleTracker)
+	peers := []networkingv1.NetworkPolicyPeer{
+		makePodPeer(cluster.RoleMatchingLabels(v1.ServerRoleTracker)),
+		makePodPeer(cluster.RoleMatchingLabels(v1.ServerRoleStorage)),
+		makePodPeer(cluster.GatewayMatchingLabels("")),
+	}
+	setIngressRules(np, cluster.RoleMatchingLabels(v1.ServerRoleTracker),
+		makeIngressRule(append(peers, makeClientPeers(cluster)...), string(v1.ServerRoleTracker)))
+}
+
+// mutateStorageNetworkPolicy lets the storage servers of a group sync with each other, and
+// trackers, gateways and clients reach them. Sidecar gateways only serve clients
+func mutateStorageNetworkPolicy(cluster *v1.FastDFS, group string, np *networkingv1.NetworkPolicy) {
+	np.Labels = cluster.GroupLabels(group)
+	peers := []networkingv1.NetworkPolicyPeer{
+		makePodPeer(cluster.GroupMatchingLabels(group)),
+		makePodPeer(cluster.RoleMatchingLabels(v1.ServerRoleTracker)),
+		makePodPeer(cluster.GatewayMatchingLabels("")),
+	}
+	rules := []networkingv1.NetworkPolicyIngressRule{
+		makeIngressRule(append(peers, makeClientPeers(cluster)...), string(v1.ServerRoleStorage), v1.HTTPPortName),
+	}
+	if cluster.GetGatewayMode() == v1.GatewayModeSidecar {
+		rules = append(rules, makeIngressRule(makeClientPeers(cluster), v1.GatewayContainerName))
+	}
+	setIngressRules(np, cluster.GroupMatchingLabels(group), rules...)
+}
+
+// mutateGatewayNetworkPolicy lets only clients download files from the standalone gateways
+func mutateGatewayNetworkPolicy(cluster *v1.FastDFS, np *networkingv1.NetworkPolicy) {
+	np.Labels = cluster.GatewayLabels("")
+	setIngressRules(np, cluster.GatewayMatchingLabels(""),
+		makeIngressRule(makeClientPeers(cluster), v1.GatewayContainerName))
+}
+
+// setIngressRules selects the guarded pods, rules without peers are dropped
+// since a rule without peers would admit any source
+func setIngressRules(np *networkingv1.NetworkPolicy, selector map[string]string,
+	rules ...networkingv1.NetworkPolicyIngressRule) {
+	np.Spec.PodSelector = metav1.LabelSelector{MatchLabels: selector}
+	np.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	np.Spec.Ingress = nil
+	for _, rule := range rules {
+		if len(rule.From) != 0 {
+			np.Spec.Ingress = append(np.Spec.Ingress, rule)
+		}
+	}
+}
+
+// makeIngressRule admits the peers at the named container ports, so port changes need no policy update
+func makeIngressRule(peers []networkingv1.NetworkPolicyPeer, ports ...string) networkingv1.NetworkPolicyIngressRule {
+	rule := networkingv1.NetworkPolicyIngressRule{From: peers}
+	for _, port := range ports {
+		protocol := corev1.ProtocolTCP
+		name := intstr.FromString(port)
+		rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &name})
+	}
+	return rule
+}
+
+func makePodPeer(labels map[string]string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: labels}}
+}
+
+// makeClientPeers is the client pods and networks listed in spec.networkPolicy
+func makeClientPeers(cluster *v1.FastDFS) []networkingv1.NetworkPolicyPeer {
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, c := range cluster.Spec.NetworkPolicy.Clients {
+		peer := networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: c.NamespaceSelector,
+			PodSelector:       c.PodSelector,
+		}
+		if peer.NamespaceSelector == nil && peer.PodSelector == nil {
+			peer.PodSelector = &metav1.LabelSelector{}
+		}
+		peers = append(peers, peer)
+	}
+	for _, cidr := range cluster.Spec.NetworkPolicy.IPBlocks {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	return peers
+}
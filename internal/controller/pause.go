@@ -0,0 +1,48 @@
+package controller
+
+import (
+	v1 "fastdfs_operator/api/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// makePausedReplicas decides the replicas of a statefulset with respect to spec.paused. A paused
+// cluster serves nothing but keeps its volumes, configuration and services, the replicas running
+// before pausing are remembered in status and given back when the cluster resumes. Otherwise
+// the statefulset gets next, the step towards the desired replicas
+func makePausedReplicas(cluster *v1.FastDFS, sts *appsv1.StatefulSet, desired, next *int32) *int32 {
+	if cluster.Spec.Paused {
+		if _, ok := cluster.Status.PausedReplicas[sts.Name]; !ok && !sts.CreationTimestamp.IsZero() && sts.Spec.Replicas != nil {
+			if cluster.Status.PausedReplicas == nil {
+				cluster.Status.PausedReplicas = map[string]int32{}
+			}
+			cluster.Status.PausedReplicas[sts.Name] = *sts.Spec.Replicas
+		}
+		paused := int32(0)
+		return &paused
+	}
+
+	// resume at once instead of scaling up one by one, but never beyond the expected size
+	if previous, ok := cluster.Status.PausedReplicas[sts.Name]; ok {
+		if previous > *desired {
+			previous = *desired
+		}
+		return &previous
+	}
+	return next
+}
+
+// forgetPausedReplicas drops the remembered replicas once the resumed statefulset is back
+// to its size, from then on it scales one by one again
+func forgetPausedReplicas(cluster *v1.FastDFS, sts *appsv1.StatefulSet) {
+	if _, ok := cluster.Status.PausedReplicas[sts.Name]; !ok || cluster.Spec.Paused {
+		return
+	}
+	if sts.Spec.Replicas == nil || sts.Status.ReadyReplicas < *sts.Spec.Replicas {
+		return
+	}
+	delete(cluster.Status.PausedReplicas, sts.Name)
+	if len(cluster.Status.PausedReplicas) == 0 {
+		cluster.Status.PausedReplicas = nil
+	}
+}
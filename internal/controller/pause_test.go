@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "fastdfs_operator/api/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+// newTestStatefulSet is an existing statefulset of the given replicas, ready ones among them
+func newTestStatefulSet(replicas, ready int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "fdfs-storage-group1", CreationTimestamp: metav1.Now()},
+		Spec:       appsv1.StatefulSetSpec{Replicas: int32Ptr(replicas)},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: ready},
+	}
+}
+
+func TestMakePausedReplicas(t *testing.T) {
+	tests := []struct {
+		name       string
+		paused     bool
+		remembered map[string]int32
+		sts        *appsv1.StatefulSet
+		desired    int32
+		next       int32
+		want       int32
+		wantMemory map[string]int32
+	}{
+		{"running", false, nil, newTestStatefulSet(2, 2), 3, 3, 3, nil},
+		{"pausing remembers the replicas", true, nil, newTestStatefulSet(3, 3), 3, 3, 0,
+			map[string]int32{"fdfs-storage-group1": 3}},
+		{"paused keeps the first remembered replicas", true, map[string]int32{"fdfs-storage-group1": 3},
+			newTestStatefulSet(0, 0), 3, 1, 0, map[string]int32{"fdfs-storage-group1": 3}},
+		{"pausing before creation remembers nothing", true, nil, &appsv1.StatefulSet{}, 3, 1, 0, nil},
+		{"resuming restores at once", false, map[string]int32{"fdfs-storage-group1": 3},
+			newTestStatefulSet(0, 0), 3, 1, 3, map[string]int32{"fdfs-storage-group1": 3}},
+		{"resuming never goes beyond desired", false, map[string]int32{"fdfs-storage-group1": 3},
+			newTestStatefulSet(0, 0), 2, 1, 2, map[string]int32{"fdfs-storage-group1": 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &v1.FastDFS{}
+			cluster.Spec.Paused = tt.paused
+			cluster.Status.PausedReplicas = tt.remembered
+			got := makePausedReplicas(cluster, tt.sts, int32Ptr(tt.desired), int32Ptr(tt.next))
+			if *got != tt.want {
+				t.Errorf("makePausedReplicas() = %d, want %d", *got, tt.want)
+			}
+			if !reflect.DeepEqual(cluster.Status.PausedReplicas, tt.wantMemory) {
+				t.Errorf("pausedReplicas = %v, want %v", cluster.Status.PausedReplicas, tt.wantMemory)
+			}
+		})
+	}
+}
+
+func TestForgetPausedReplicas(t *testing.T) {
+	tests := []struct {
+		name       string
+		paused     bool
+		sts        *appsv1.StatefulSet
+		wantMemory map[string]int32
+	}{
+		{"still paused", true, newTestStatefulSet(0, 0), map[string]int32{"fdfs-storage-group1": 3}},
+		{"resuming", false, newTestStatefulSet(3, 1), map[string]int32{"fdfs-storage-group1": 3}},
+		{"resumed", false, newTestStatefulSet(3, 3), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &v1.FastDFS{}
+			cluster.Spec.Paused = tt.paused
+			cluster.Status.PausedReplicas = map[string]int32{"fdfs-storage-group1": 3}
+			forgetPausedReplicas(cluster, tt.sts)
+			if !reflect.DeepEqual(cluster.Status.PausedReplicas, tt.wantMemory) {
+				t.Errorf("pausedReplicas = %v, want %v", cluster.Status.PausedReplicas, tt.wantMemory)
+			}
+		})
+	}
+}
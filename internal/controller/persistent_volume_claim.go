@@ -15,12 +15,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *FastDFSReconciler) getStorageClass(ctx context.Context, cluster *v1.FastDFS) (*storagev1.StorageClass, error) {
+func (r *FastDFSReconciler) getStorageClass(ctx context.Context, group *v1.StorageGroup) (*storagev1.StorageClass, error) {
 	var sc *storagev1.StorageClass
 
-	if cluster.Spec.Storage.StorageClass != nil {
+	if group.StorageClass != nil {
 		sc = &storagev1.StorageClass{}
-		if err := r.Get(ctx, client.ObjectKey{Name: *cluster.Spec.Storage.StorageClass}, sc); err != nil {
+		if err := r.Get(ctx, client.ObjectKey{Name: *group.StorageClass}, sc); err != nil {
 			return nil, err
 		}
 	} else {
@@ -37,22 +37,33 @@ func (r *FastDFSReconciler) ReconcilePersistentVolumeClaim(ctx context.Context,
 	cluster, _ := object.(*v1.FastDFS)
 	logr.FromContext(ctx).Info("reconcile cluster pvc")
 
-	if sc, err := r.getStorageClass(ctx, cluster); err != nil {
+	groups := cluster.GetStorageGroups()
+	for i := range groups {
+		if result, err := r.reconcileGroupPersistentVolumeClaim(ctx, cluster, &groups[i]); err != nil || result.RequeueRequest {
+			return result, err
+		}
+	}
+
+	return reconcile.Continue()
+}
+
+func (r *FastDFSReconciler) reconcileGroupPersistentVolumeClaim(ctx context.Context, cluster *v1.FastDFS, group *v1.StorageGroup) (reconcile.Result, error) {
+	if sc, err := r.getStorageClass(ctx, group); err != nil {
 		return reconcile.RequeueOnError(err)
 	} else if sc == nil || sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
 		return reconcile.Continue()
 	}
 
-	for ord := 0; ord < int(cluster.Status.Replicas); ord++ {
+	for ord := 0; ord < int(cluster.GetStorageGroupStatus(group.Name).Replicas); ord++ {
 		pvc := &corev1.PersistentVolumeClaim{}
-		if err := r.Get(ctx, cluster.GetPersistentVolumeClaimNamespacedName(ord), pvc); err != nil && !apierrors.IsNotFound(err) {
+		if err := r.Get(ctx, cluster.GetPersistentVolumeClaimNamespacedName(group.Name, ord), pvc); err != nil && !apierrors.IsNotFound(err) {
 			return reconcile.RequeueOnError(err)
 		} else if err != nil || util.IsObjectBeingDeleted(pvc) {
 			continue
 		}
 
 		currentSize := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
-		expectSize := r.makePVCStorageSize(cluster)
+		expectSize := r.makePVCStorageSize(cluster, group)
 		if (&currentSize).Cmp(expectSize) < 0 {
 			pvc.Spec.Resources.Requests[corev1.ResourceStorage] = expectSize
 
@@ -68,15 +79,15 @@ func (r *FastDFSReconciler) ReconcilePersistentVolumeClaim(ctx context.Context,
 	return reconcile.Continue()
 }
 
-func (r *FastDFSReconciler) getPVCList(ctx context.Context, cluster *v1.FastDFS) (pvList corev1.PersistentVolumeClaimList, err error) {
+func (r *FastDFSReconciler) getPVCList(ctx context.Context, cluster *v1.FastDFS, group string) (pvList corev1.PersistentVolumeClaimList, err error) {
 	pvcList := &corev1.PersistentVolumeClaimList{}
 	err = r.List(ctx, pvcList,
-		client.InNamespace(cluster.Namespace), client.MatchingLabels(cluster.ResourceMatchingLabels()))
+		client.InNamespace(cluster.Namespace), client.MatchingLabels(cluster.GroupMatchingLabels(group)))
 	return *pvcList, err
 }
 
-func (r *FastDFSReconciler) cleanupPVCs(ctx context.Context, cluster *v1.FastDFS, replicas int32) error {
-	pvcList, err := r.getPVCList(ctx, cluster)
+func (r *FastDFSReconciler) cleanupPVCs(ctx context.Context, cluster *v1.FastDFS, group string, replicas int32) error {
+	pvcList, err := r.getPVCList(ctx, cluster, group)
 	if err != nil {
 		return err
 	}
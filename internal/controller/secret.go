@@ -0,0 +1,311 @@
+package controller
+
+import (
+	"context"
+	v1 "fastdfs_operator/api/v1"
+	"fmt"
+	"strings"
+	"time"
+
+	"fastdfs_operator/pkg/utils"
+
+	"github.com/fearlesschenc/operator-utils/pkg/reconcile"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ReconcileSecret renders http.conf into a secret, since it carries the anti-steal key
+func (r *FastDFSReconciler) ReconcileSecret(ctx context.Context, object metav1.Object) (reconcile.Result, error) {
+	cluster, _ := object.(*v1.FastDFS)
+	logr.FromContext(ctx).Info("reconcile cluster secret")
+
+	if err := r.rotateAntiStealKey(ctx, cluster); err != nil {
+		setCondition(cluster, v1.ConditionConfigApplied, metav1.ConditionFalse, "ServerAuthUnavailable", err.Error())
+		return reconcile.RequeueOnError(err)
+	}
+	secretKey, checkToken, err := r.getServerAuth(ctx, cluster)
+	if err != nil {
+		setCondition(cluster, v1.ConditionConfigApplied, metav1.ConditionFalse, "ServerAuthUnavailable", err.Error())
+		return reconcile.RequeueOnError(err)
+	}
+	previousKey, expiration, err := r.getPreviousAntiStealKey(ctx, cluster, secretKey)
+	if err != nil {
+		return reconcile.RequeueOnError(err)
+	}
+
+	secret := makeSecret(cluster.GetHTTPConfigSecretNamespacedName())
+	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Labels = cluster.ResourceLabels()
+		secret.Data = map[string][]byte{
+			v1.HTTPConfigFile:     []byte(makeHTTPConf(cluster, secretKey, checkToken)),
+			v1.AntiStealSecretKey: []byte(secretKey),
+		}
+		// the download gateway keeps accepting tokens signed with the previous key
+		if previousKey != "" {
+			secret.Data[v1.PreviousHTTPConfigFile] = []byte(makeHTTPConf(cluster, previousKey, checkToken))
+			secret.Data[v1.PreviousSecretKey] = []byte(previousKey)
+			secret.Data[v1.PreviousKeyExpirationKey] = []byte(expiration.UTC().Format(time.RFC3339))
+		}
+		return controllerutil.SetControllerReference(cluster, secret, r.Scheme)
+	}); err != nil {
+		setCondition(cluster, v1.ConditionConfigApplied, metav1.ConditionFalse, "SecretSyncFailed", err.Error())
+		return reconcile.RequeueOnError(err)
+	} else {
+		switch result {
+		case controllerutil.OperationResultCreated:
+			logr.FromContext(ctx).Info("created http config secret")
+		case controllerutil.OperationResultUpdated:
+			logr.FromContext(ctx).Info("updated http config secret")
+			data, err := r.getConfigData(ctx, cluster)
+			if err != nil {
+				return reconcile.RequeueOnError(err)
+			}
+			r.Eventf(cluster, corev1.EventTypeNormal, "ConfigUpdated",
+				fmt.Sprintf("updated fastdfs http configuration, %s", describeConfigHashes(cluster, data)))
+		}
+	}
+	return reconcile.Continue()
+}
+
+// getServerAuth resolves the anti-steal key and whether tokens are checked,
+// references take precedence over the values inlined in the spec
+func (r *FastDFSReconciler) getServerAuth(ctx context.Context, cluster *v1.FastDFS) (string, bool, error) {
+	auth := cluster.Spec.ServerAuth
+	checkToken := auth.IsTokenChecked()
+	if auth.TokenRef != nil {
+		token, err := r.getSecretValue(ctx, cluster.Namespace, auth.TokenRef)
+		if err != nil {
+			return "", false, err
+		}
+		checkToken = strings.TrimSpace(token) == "true"
+	}
+
+	var secretKey string
+	var err error
+	switch {
+	case auth.SecretRef != nil:
+		secretKey, err = r.getSecretValue(ctx, cluster.Namespace, auth.SecretRef)
+	case auth.Secret != "":
+		secretKey = auth.Secret
+	default:
+		secretKey, err = r.ensureAntiStealSecret(ctx, cluster)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	secretKey = strings.TrimSpace(secretKey)
+	if len(secretKey) > v1.MaxServerAuthSecretSize {
+		return "", false, fmt.Errorf("anti-steal secret key has %d bytes, at most %d bytes are allowed",
+			len(secretKey), v1.MaxServerAuthSecretSize)
+	}
+	return secretKey, checkToken, nil
+}
+
+func (r *FastDFSReconciler) getSecretValue(ctx context.Context, namespace string,
+	selector *corev1.SecretKeySelector) (string, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: selector.Name}, secret); err != nil {
+		if apierrors.IsNotFound(err) && selector.Optional != nil && *selector.Optional {
+			return "", nil
+		}
+		return "", err
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok && (selector.Optional == nil || !*selector.Optional) {
+		return "", fmt.Errorf("key %s not found in secret %s", selector.Key, selector.Name)
+	}
+	return string(value), nil
+}
+
+// ensureAntiStealSecret generates the anti-steal key once, the key is kept
+// as long as the secret exists so tokens issued before stay valid
+func (r *FastDFSReconciler) ensureAntiStealSecret(ctx context.Context, cluster *v1.FastDFS) (string, error) {
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, cluster.GetAntiStealSecretNamespacedName(), secret)
+	if err == nil {
+		return string(secret.Data[v1.AntiStealSecretKey]), nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+
+	// two hex characters per byte keep the key within the 128 bytes FastDFS accepts
+	key, err := utils.RandomHex(v1.MaxServerAuthSecretSize / 4)
+	if err != nil {
+		return "", err
+	}
+	secret = makeSecret(cluster.GetAntiStealSecretNamespacedName())
+	secret.Labels = cluster.ResourceLabels()
+	secret.Data = map[string][]byte{v1.AntiStealSecretKey: []byte(key)}
+	// a fresh key satisfies any pending rotation request
+	request := cluster.Annotations[v1.RotateAntiStealKeyAnnotation]
+	if request != "" {
+		secret.Data[v1.RotationRequestKey] = []byte(request)
+	}
+	if err := controllerutil.SetControllerReference(cluster, secret, r.Scheme); err != nil {
+		return "", err
+	}
+	if err := r.Create(ctx, secret); err != nil {
+		return "", err
+	}
+	if request != "" {
+		cluster.Status.ServerAuth.RotationRequest = request
+	}
+	logr.FromContext(ctx).Info("generated anti-steal secret key")
+	r.Eventf(cluster, corev1.EventTypeNormal, "SecretKeyGenerated",
+		fmt.Sprintf("generated anti-steal secret key into secret %s", secret.Name))
+	return key, nil
+}
+
+// rotateAntiStealKey replaces the generated anti-steal key once the rotate annotation
+// changes, user provided keys are rotated by updating the referenced secret instead. The
+// handled request is kept in the secret along with the key, so a lost status update does
+// not rotate the key twice
+func (r *FastDFSReconciler) rotateAntiStealKey(ctx context.Context, cluster *v1.FastDFS) error {
+	status := &cluster.Status.ServerAuth
+	request := cluster.Annotations[v1.RotateAntiStealKeyAnnotation]
+	if request == "" {
+		return nil
+	}
+	auth := cluster.Spec.ServerAuth
+	if auth.SecretRef != nil || auth.Secret != "" {
+		if request != status.RotationRequest {
+			status.RotationRequest = request
+			r.Eventf(cluster, corev1.EventTypeWarning, "AntiStealKeyNotRotated",
+				"anti-steal key is provided by the user, rotate it by updating the referenced secret")
+		}
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, cluster.GetAntiStealSecretNamespacedName(), secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			// a fresh key is generated anyway
+			return nil
+		}
+		return err
+	}
+	if string(secret.Data[v1.RotationRequestKey]) == request {
+		status.RotationRequest = request
+		return nil
+	}
+	// only one previous key is accepted, wait for the running rotation to complete
+	if status.Phase == v1.ServerAuthPhaseRotating {
+		return nil
+	}
+
+	key, err := utils.RandomHex(v1.MaxServerAuthSecretSize / 4)
+	if err != nil {
+		return err
+	}
+	secret.Data = map[string][]byte{
+		v1.AntiStealSecretKey: []byte(key),
+		v1.RotationRequestKey: []byte(request),
+	}
+	if err := r.Update(ctx, secret); err != nil {
+		return err
+	}
+	status.RotationRequest = request
+	logr.FromContext(ctx).Info("rotated anti-steal secret key", "request", request)
+	return nil
+}
+
+// getPreviousAntiStealKey tracks key changes against the key last rendered, a replaced key
+// is kept until the grace period expires. The expiration is kept in the rendered secret along
+// with the key, so a lost status update does not start the grace period over
+func (r *FastDFSReconciler) getPreviousAntiStealKey(ctx context.Context, cluster *v1.FastDFS,
+	secretKey string) (string, *metav1.Time, error) {
+	status := &cluster.Status.ServerAuth
+	rendered := &corev1.Secret{}
+	if err := r.Get(ctx, cluster.GetHTTPConfigSecretNamespacedName(), rendered); err != nil {
+		if apierrors.IsNotFound(err) {
+			status.Phase = v1.ServerAuthPhaseStable
+			return "", nil, nil
+		}
+		return "", nil, err
+	}
+
+	now := metav1.Now()
+	currentKey := string(rendered.Data[v1.AntiStealSecretKey])
+	previousKey := string(rendered.Data[v1.PreviousSecretKey])
+	var expiration *metav1.Time
+	if t, err := time.Parse(time.RFC3339, string(rendered.Data[v1.PreviousKeyExpirationKey])); err == nil {
+		expiration = &metav1.Time{Time: t}
+	} else if previousKey != "" {
+		// rendered before the expiration was kept in the secret
+		expiration = status.PreviousKeyExpirationTime
+	}
+	gracePeriod := rotationGracePeriod(cluster)
+	if currentKey != "" && currentKey != secretKey {
+		previousKey = currentKey
+		expiration = nil
+		status.RotationStartTime = &now
+		if gracePeriod > 0 {
+			r.Eventf(cluster, corev1.EventTypeNormal, "AntiStealKeyRotating",
+				fmt.Sprintf("anti-steal key changed, tokens of the previous key are accepted for %s", gracePeriod))
+		}
+	}
+	if previousKey == "" {
+		status.Phase = v1.ServerAuthPhaseStable
+		status.PreviousKeyExpirationTime = nil
+		return "", nil, nil
+	}
+
+	if expiration == nil {
+		expiration = &metav1.Time{Time: now.Add(gracePeriod)}
+	}
+	if !now.Time.Before(expiration.Time) {
+		status.Phase = v1.ServerAuthPhaseStable
+		status.PreviousKeyExpirationTime = nil
+		status.LastRotationTime = &now
+		r.Eventf(cluster, corev1.EventTypeNormal, "AntiStealKeyRotated",
+			"tokens signed with the previous anti-steal key are no longer accepted")
+		return "", nil, nil
+	}
+	status.Phase = v1.ServerAuthPhaseRotating
+	status.PreviousKeyExpirationTime = expiration
+	return previousKey, expiration, nil
+}
+
+// rotationGracePeriod is how long the previous anti-steal key is accepted, only the
+// standalone gateways serve it so other clusters drop it at once
+func rotationGracePeriod(cluster *v1.FastDFS) time.Duration {
+	if cluster.GetGatewayMode() != v1.GatewayModeStandalone {
+		return 0
+	}
+	return cluster.Spec.ServerAuth.GetRotationGracePeriod()
+}
+
+// getConfigData gathers every rendered config file from the configmap and the http config secret
+func (r *FastDFSReconciler) getConfigData(ctx context.Context, cluster *v1.FastDFS) (map[string]string, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, cluster.GetConfigMapNamespacedName(), cm); err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, cluster.GetHTTPConfigSecretNamespacedName(), secret); err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{}
+	for k, v := range cm.Data {
+		data[k] = v
+	}
+	for k, v := range secret.Data {
+		data[k] = string(v)
+	}
+	return data, nil
+}
+
+func makeSecret(nn types.NamespacedName) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nn.Name,
+			Namespace: nn.Namespace,
+		},
+	}
+}
@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "fastdfs_operator/api/v1"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// secretClient keeps secrets in memory, other objects are not served
+type secretClient struct {
+	client.Client
+	secrets map[types.NamespacedName]*corev1.Secret
+}
+
+func (c *secretClient) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	secret, ok := c.secrets[key]
+	if !ok {
+		return newNotFound(key.Name)
+	}
+	secret.DeepCopyInto(obj.(*corev1.Secret))
+	return nil
+}
+
+func newNotFound(name string) error {
+	return apierrors.NewNotFound(schema.GroupResource{}, name)
+}
+
+func (c *secretClient) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	secret := obj.(*corev1.Secret)
+	c.secrets[types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}] = secret.DeepCopy()
+	return nil
+}
+
+func newSecretReconciler(secrets ...*corev1.Secret) (*FastDFSReconciler, *secretClient) {
+	c := &secretClient{secrets: map[types.NamespacedName]*corev1.Secret{}}
+	for _, secret := range secrets {
+		c.secrets[types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}] = secret
+	}
+	return &FastDFSReconciler{Client: c, Recorder: record.NewFakeRecorder(10)}, c
+}
+
+// testContext carries the logger reconcile steps expect
+func testContext() context.Context {
+	return logr.NewContext(context.TODO(), logr.Discard())
+}
+
+func newTestSecret(nn types.NamespacedName, data map[string]string) *corev1.Secret {
+	secret := makeSecret(nn)
+	secret.Data = map[string][]byte{}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func TestRotateAntiStealKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		request     string
+		userKey     bool
+		phase       v1.ServerAuthPhase
+		secret      map[string]string
+		rotated     bool
+		wantRequest string
+	}{
+		{"no request", "", false, v1.ServerAuthPhaseStable,
+			map[string]string{v1.AntiStealSecretKey: "old"}, false, ""},
+		{"new request", "1", false, v1.ServerAuthPhaseStable,
+			map[string]string{v1.AntiStealSecretKey: "old"}, true, "1"},
+		{"request handled before a lost status update", "1", false, v1.ServerAuthPhaseStable,
+			map[string]string{v1.AntiStealSecretKey: "old", v1.RotationRequestKey: "1"}, false, "1"},
+		{"another request", "2", false, v1.ServerAuthPhaseStable,
+			map[string]string{v1.AntiStealSecretKey: "old", v1.RotationRequestKey: "1"}, true, "2"},
+		{"rotation in progress", "2", false, v1.ServerAuthPhaseRotating,
+			map[string]string{v1.AntiStealSecretKey: "old", v1.RotationRequestKey: "1"}, false, ""},
+		{"user provided key", "1", true, v1.ServerAuthPhaseStable,
+			map[string]string{v1.AntiStealSecretKey: "old"}, false, "1"},
+		{"no generated key yet", "1", false, v1.ServerAuthPhaseStable, nil, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster("6.12")
+			cluster.Annotations = map[string]string{v1.RotateAntiStealKeyAnnotation: tt.request}
+			cluster.Status.ServerAuth.Phase = tt.phase
+			if tt.userKey {
+				cluster.Spec.ServerAuth.Secret = "user"
+			}
+			var secrets []*corev1.Secret
+			if tt.secret != nil {
+				secrets = append(secrets, newTestSecret(cluster.GetAntiStealSecretNamespacedName(), tt.secret))
+			}
+			r, c := newSecretReconciler(secrets...)
+
+			if err := r.rotateAntiStealKey(testContext(), cluster); err != nil {
+				t.Fatalf("rotateAntiStealKey() error = %v", err)
+			}
+			if got := cluster.Status.ServerAuth.RotationRequest; got != tt.wantRequest {
+				t.Errorf("rotationRequest = %q, want %q", got, tt.wantRequest)
+			}
+			if tt.secret == nil {
+				return
+			}
+			secret := c.secrets[cluster.GetAntiStealSecretNamespacedName()]
+			key := string(secret.Data[v1.AntiStealSecretKey])
+			if rotated := key != "old"; rotated != tt.rotated {
+				t.Errorf("key rotated = %v, want %v", rotated, tt.rotated)
+			}
+			if tt.rotated && string(secret.Data[v1.RotationRequestKey]) != tt.request {
+				t.Errorf("secret records request %q, want %q", secret.Data[v1.RotationRequestKey], tt.request)
+			}
+		})
+	}
+}
+
+func TestGetPreviousAntiStealKey(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	past := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		name           string
+		gateway        *v1.GatewayOption
+		rendered       map[string]string
+		key            string
+		wantPrevious   string
+		wantPhase      v1.ServerAuthPhase
+		wantExpiration *time.Time
+	}{
+		{"first render", &v1.GatewayOption{}, nil, "new", "", v1.ServerAuthPhaseStable, nil},
+		{"key unchanged", &v1.GatewayOption{}, map[string]string{v1.AntiStealSecretKey: "new"},
+			"new", "", v1.ServerAuthPhaseStable, nil},
+		{"key replaced", &v1.GatewayOption{}, map[string]string{v1.AntiStealSecretKey: "old"},
+			"new", "old", v1.ServerAuthPhaseRotating, nil},
+		{"key replaced with sidecars", &v1.GatewayOption{Mode: v1.GatewayModeSidecar},
+			map[string]string{v1.AntiStealSecretKey: "old"}, "new", "", v1.ServerAuthPhaseStable, nil},
+		{"key replaced without gateway", nil, map[string]string{v1.AntiStealSecretKey: "old"},
+			"new", "", v1.ServerAuthPhaseStable, nil},
+		{"grace period running", &v1.GatewayOption{}, map[string]string{
+			v1.AntiStealSecretKey:       "new",
+			v1.PreviousSecretKey:        "old",
+			v1.PreviousKeyExpirationKey: future.Format(time.RFC3339),
+		}, "new", "old", v1.ServerAuthPhaseRotating, &future},
+		{"grace period expired", &v1.GatewayOption{}, map[string]string{
+			v1.AntiStealSecretKey:       "new",
+			v1.PreviousSecretKey:        "old",
+			v1.PreviousKeyExpirationKey: past.Format(time.RFC3339),
+		}, "new", "", v1.ServerAuthPhaseStable, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster("6.12")
+			cluster.Spec.Gateway = tt.gateway
+			cluster.Spec.ServerAuth.RotationGracePeriodSeconds = int32Ptr(60)
+			var secrets []*corev1.Secret
+			if tt.rendered != nil {
+				secrets = append(secrets, newTestSecret(cluster.GetHTTPConfigSecretNamespacedName(), tt.rendered))
+			}
+			r, _ := newSecretReconciler(secrets...)
+
+			start := time.Now()
+			previous, expiration, err := r.getPreviousAntiStealKey(testContext(), cluster, tt.key)
+			if err != nil {
+				t.Fatalf("getPreviousAntiStealKey() error = %v", err)
+			}
+			if previous != tt.wantPrevious {
+				t.Errorf("previous key = %q, want %q", previous, tt.wantPrevious)
+			}
+			if phase := cluster.Status.ServerAuth.Phase; phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", phase, tt.wantPhase)
+			}
+			switch {
+			case previous == "":
+				if expiration != nil {
+					t.Errorf("expiration = %v, want none", expiration)
+				}
+			case tt.wantExpiration != nil:
+				if !expiration.Time.Equal(*tt.wantExpiration) {
+					t.Errorf("expiration = %v, want %v", expiration, tt.wantExpiration)
+				}
+			default:
+				// a new rotation expires after the grace period
+				if expiration.Time.Before(start.Add(time.Minute)) || expiration.Time.After(time.Now().Add(time.Minute)) {
+					t.Errorf("expiration = %v, want a minute from now", expiration)
+				}
+			}
+			if !equalTime(cluster.Status.ServerAuth.PreviousKeyExpirationTime, expiration) {
+				t.Errorf("status expiration = %v, want %v", cluster.Status.ServerAuth.PreviousKeyExpirationTime, expiration)
+			}
+		})
+	}
+}
+
+func equalTime(a, b *metav1.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(b)
+}
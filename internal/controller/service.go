@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	v1 "fastdfs_operator/api/v1"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/fearlesschenc/operator-utils/pkg/reconcile"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func (r *FastDFSReconciler) ReconcileService(ctx context.Context, object metav1.Object) (reconcile.Result, error) {
+	cluster, _ := object.(*v1.FastDFS)
+	r.Log.Info("reconcile cluster headless service")
+
+	for _, role := range []v1.ServerRole{v1.ServerRoleTracker, v1.ServerRoleStorage} {
+		svc := makeService(cluster.GetHeadlessServiceNamespacedName(role))
+		if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+			return r.mutateHeadlessService(cluster, role, svc)
+		}); err != nil {
+			return reconcile.RequeueOnError(err)
+		} else {
+			switch result {
+			case controllerutil.OperationResultCreated:
+				r.Log.Info("created headless service", "role", role)
+				r.Eventf(cluster, corev1.EventTypeNormal, "ServiceCreated",
+					fmt.Sprintf("created fastdfs %s headless service", role))
+			case controllerutil.OperationResultUpdated:
+				r.Log.Info("updated headless service", "role", role)
+				r.Eventf(cluster, corev1.EventTypeNormal, "ServiceUpdated",
+					fmt.Sprintf("updated fastdfs %s headless service", role))
+			}
+		}
+	}
+
+	if err := r.reconcileExternalServices(ctx, cluster); err != nil {
+		return reconcile.RequeueOnError(err)
+	}
+
+	svc := makeService(cluster.GetTrackerServiceNamespacedName())
+	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+		return r.mutateTrackerService(cluster, svc)
+	}); err != nil {
+		return reconcile.RequeueOnError(err)
+	} else {
+		switch result {
+		case controllerutil.OperationResultCreated:
+			r.Log.Info("created tracker service")
+			r.Eventf(cluster, corev1.EventTypeNormal, "ServiceCreated", "created fastdfs tracker service")
+		case controllerutil.OperationResultUpdated:
+			r.Log.Info("updated tracker service")
+			r.Eventf(cluster, corev1.EventTypeNormal, "ServiceUpdated", "updated fastdfs tracker service")
+		}
+	}
+	observeTrackerService(cluster, svc)
+	return reconcile.Continue()
+}
+
+// observeTrackerService publishes the addresses the tracker service is reachable at
+func observeTrackerService(cluster *v1.FastDFS, svc *corev1.Service) {
+	endpoints := &cluster.Status.Endpoints
+	port := svc.Spec.Ports[0]
+	endpoints.Tracker = fmt.Sprintf("%s.%s.svc:%d", svc.Name, svc.Namespace, port.Port)
+	endpoints.TrackerNodePort = port.NodePort
+	endpoints.TrackerExternal = makeLoadBalancerAddresses(svc, port.Port)
+}
+
+func makeLoadBalancerAddresses(svc *corev1.Service, port int32) []string {
+	var addresses []string
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		host := ingress.IP
+		if host == "" {
+			host = ingress.Hostname
+		}
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(int(port))))
+	}
+	return addresses
+}
+
+// reconcileExternalServices keeps a service for every expected storage pod while
+// external access is enabled, and publishes the address each pod advertises
+func (r *FastDFSReconciler) reconcileExternalServices(ctx context.Context, cluster *v1.FastDFS) error {
+	enabled := cluster.Spec.Storage != nil && cluster.Spec.Storage.ExternalService != nil
+	var endpoints []v1.StorageEndpoint
+	expected := map[string]bool{}
+	for _, group := range cluster.GetStorageGroups() {
+		for ordinal := int32(0); enabled && ordinal < *group.Replicas; ordinal++ {
+			svc := makeService(cluster.GetExternalServiceNamespacedName(group.Name, ordinal))
+			expected[svc.Name] = true
+			if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+				return r.mutateExternalService(cluster, group.Name, ordinal, svc)
+			}); err != nil {
+				return err
+			} else if result == controllerutil.OperationResultCreated {
+				r.Log.Info("created external service", "service", svc.Name)
+				r.Eventf(cluster, corev1.EventTypeNormal, "ServiceCreated",
+					fmt.Sprintf("created fastdfs external service %s", svc.Name))
+			}
+
+			if address := getLoadBalancerAddress(svc); address != "" {
+				endpoints = append(endpoints, v1.StorageEndpoint{
+					Pod:     cluster.GetPodName(group.Name, ordinal),
+					Group:   group.Name,
+					Address: address,
+				})
+			}
+		}
+	}
+
+	// only external services carry a group label, so services of groups no longer
+	// in the spec are found as well
+	services := &corev1.ServiceList{}
+	if err := r.List(ctx, services, client.InNamespace(cluster.Namespace),
+		client.MatchingLabels(cluster.RoleMatchingLabels(v1.ServerRoleStorage))); err != nil {
+		return err
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		if _, ok := svc.Labels[v1.GroupLabelKey]; !ok || expected[svc.Name] || !metav1.IsControlledBy(svc, cluster) {
+			continue
+		}
+		if err := r.Delete(ctx, svc); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		r.Log.Info("deleted external service", "service", svc.Name)
+	}
+	cluster.Status.Endpoints.Storage = endpoints
+	return nil
+}
+
+// getLoadBalancerAddress is the first address of the load balancer of the service
+func getLoadBalancerAddress(svc *corev1.Service) string {
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			return ingress.IP
+		}
+		return ingress.Hostname
+	}
+	return ""
+}
@@ -15,27 +15,111 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func (r *FastDFSReconciler) ReconcileStatefulSet(ctx context.Context, object metav1.Object) (reconcile.Result, error) {
+func (r *FastDFSReconciler) ReconcileTrackerStatefulSet(ctx context.Context, object metav1.Object) (reconcile.Result, error) {
 	cluster, _ := object.(*v1.FastDFS)
-	r.Log.Info("reconcile cluster statefulset")
+	r.Log.Info("reconcile cluster tracker statefulset")
 
-	sts := r.makeStatefulSet(cluster)
+	sts := r.makeStatefulSet(cluster.GetTrackerStatefulSetNamespacedName())
 	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, sts, func() error {
-		err := r.mutateStatefulSet(cluster, sts)
+		err := r.mutateTrackerStatefulSet(cluster, sts)
 		if err != nil {
-			r.Log.Error(err, "failed to mutate statefulset")
+			r.Log.Error(err, "failed to mutate tracker statefulset")
+		}
+		return err
+	}); err != nil {
+		return reconcile.RequeueOnError(err)
+	} else {
+		switch result {
+		case controllerutil.OperationResultCreated:
+			r.Log.Info("created tracker statefulset")
+			r.Eventf(cluster, corev1.EventTypeNormal, "StatefulSetCreated", "created fastdfs tracker statefulset")
+		case controllerutil.OperationResultUpdated:
+			r.Log.Info("updated tracker statefulset")
+			r.Eventf(cluster, corev1.EventTypeNormal, "StatefulSetUpdated", "updated fastdfs tracker statefulset")
+		}
+	}
+	forgetPausedReplicas(cluster, sts)
+	cluster.Status.Tracker.Replicas = sts.Status.Replicas
+	cluster.Status.Tracker.ReadyReplicas = sts.Status.ReadyReplicas
+	cluster.Status.Tracker.UpdatedReplicas = sts.Status.UpdatedReplicas
+	if isRolledOut(sts) {
+		cluster.Status.Tracker.Version = sts.Annotations[v1.VersionAnnotation]
+	}
+	return reconcile.Continue()
+}
+
+func (r *FastDFSReconciler) ReconcileStorageStatefulSet(ctx context.Context, object metav1.Object) (reconcile.Result, error) {
+	cluster, _ := object.(*v1.FastDFS)
+	r.Log.Info("reconcile cluster storage statefulset")
+
+	// storage groups are upgraded one after another once all trackers run the expected release
+	upgradable := cluster.Status.Tracker.Version == cluster.Spec.Version
+	groups := cluster.GetStorageGroups()
+	for i := range groups {
+		if result, err := r.reconcileStorageGroup(ctx, cluster, &groups[i], upgradable); err != nil || result.RequeueRequest {
+			return result, err
+		}
+		upgradable = upgradable && cluster.GetStorageGroupStatus(groups[i].Name).Version == cluster.Spec.Version
+	}
+	if upgradable && cluster.Status.Version != cluster.Spec.Version {
+		if cluster.Status.Version != "" {
+			r.Eventf(cluster, corev1.EventTypeNormal, "Upgraded",
+				fmt.Sprintf("upgraded fastdfs from %s to %s", cluster.Status.Version, cluster.Spec.Version))
+		}
+		cluster.Status.Version = cluster.Spec.Version
+	}
+
+	cluster.Status.Replicas, cluster.Status.CurrentStatefulSetReplicas = 0, 0
+	cluster.Status.ReadyReplicas, cluster.Status.UpdatedReplicas = 0, 0
+	for _, group := range groups {
+		status := cluster.GetStorageGroupStatus(group.Name)
+		cluster.Status.Replicas += status.Replicas
+		cluster.Status.CurrentStatefulSetReplicas += status.CurrentStatefulSetReplicas
+		cluster.Status.ReadyReplicas += status.ReadyReplicas
+		cluster.Status.UpdatedReplicas += status.UpdatedReplicas
+	}
+	observerReplicas := cluster.Status.Replicas - *cluster.Spec.ParticipantReplicas
+	if observerReplicas < 0 {
+		observerReplicas = 0
+	}
+	cluster.Status.ObserverReplicas = observerReplicas
+	cluster.Status.ParticipantReplicas = cluster.Status.Replicas - cluster.Status.ObserverReplicas
+	return r.reconcilePods(ctx, cluster)
+}
+
+func (r *FastDFSReconciler) reconcileStorageGroup(ctx context.Context, cluster *v1.FastDFS, group *v1.StorageGroup,
+	upgradable bool) (reconcile.Result, error) {
+	status := cluster.GetStorageGroupStatus(group.Name)
+
+	sts := r.makeStatefulSet(cluster.GetStorageStatefulSetNamespacedName(group.Name))
+	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, sts, func() error {
+		if !sts.CreationTimestamp.IsZero() {
+			// statefulset is the source of truth of replicas already rolled out
+			observeStatefulSet(status, sts)
+		}
+
+		// hold the running release until it is the turn of the group to upgrade
+		version := cluster.Spec.Version
+		if current := sts.Annotations[v1.VersionAnnotation]; !upgradable && current != "" {
+			version = current
+		}
+
+		err := r.mutateStorageStatefulSet(cluster, group, version, sts)
+		if err != nil {
+			r.Log.Error(err, "failed to mutate storage statefulset", "group", group.Name)
 			return err
 		}
 
-		pvcBeingDeleted, err := r.isPVCBeingDeleted(ctx, cluster, *sts.Spec.Replicas)
+		pvcBeingDeleted, err := r.isPVCBeingDeleted(ctx, cluster, group.Name, *sts.Spec.Replicas)
 		if err != nil {
-			r.Log.Error(err, "failed to check if pvc is being deleted")
+			r.Log.Error(err, "failed to check if pvc is being deleted", "group", group.Name)
 			return err
 		}
 
-		if pvcBeingDeleted && cluster.Status.CurrentStatefulSetReplicas < *sts.Spec.Replicas {
-			return fmt.Errorf("current replicas: %d, target replicas: %d, need to wait for pvc deleted",
-				cluster.Status.CurrentStatefulSetReplicas,
+		if pvcBeingDeleted && status.CurrentStatefulSetReplicas < *sts.Spec.Replicas {
+			return fmt.Errorf("group %s current replicas: %d, target replicas: %d, need to wait for pvc deleted",
+				group.Name,
+				status.CurrentStatefulSetReplicas,
 				*sts.Spec.Replicas)
 		}
 
@@ -45,11 +129,13 @@ func (r *FastDFSReconciler) ReconcileStatefulSet(ctx context.Context, object met
 	} else {
 		switch result {
 		case controllerutil.OperationResultCreated:
-			r.Log.Info("created statefulset")
-			r.Eventf(cluster, corev1.EventTypeNormal, "StatefulSetCreated", "created fastdfs statefulset")
+			r.Log.Info("created storage statefulset", "group", group.Name)
+			r.Eventf(cluster, corev1.EventTypeNormal, "StatefulSetCreated",
+				fmt.Sprintf("created fastdfs storage statefulset of %s", group.Name))
 		case controllerutil.OperationResultUpdated:
-			r.Log.Info("updated statefulset")
-			r.Eventf(cluster, corev1.EventTypeNormal, "StatefulSetUpdated", "updated fastdfs statefulset")
+			r.Log.Info("updated storage statefulset", "group", group.Name)
+			r.Eventf(cluster, corev1.EventTypeNormal, "StatefulSetUpdated",
+				fmt.Sprintf("updated fastdfs storage statefulset of %s", group.Name))
 
 			_ = wait.Poll(time.Second, time.Second*30, func() (done bool, err error) {
 				if err = r.Get(ctx, client.ObjectKeyFromObject(sts), sts); err != nil {
@@ -58,21 +144,34 @@ func (r *FastDFSReconciler) ReconcileStatefulSet(ctx context.Context, object met
 				return isUpdating(sts), nil
 			})
 
-			if cluster.Status.CurrentStatefulSetReplicas > *sts.Spec.Replicas {
-				if err := r.cleanupPVCs(ctx, cluster, *sts.Spec.Replicas); err != nil {
+			// volumes of a paused cluster are kept for resuming
+			if !cluster.Spec.Paused && status.CurrentStatefulSetReplicas > *sts.Spec.Replicas {
+				if err := r.cleanupPVCs(ctx, cluster, group.Name, *sts.Spec.Replicas); err != nil {
 					return reconcile.RequeueOnError(err)
 				}
 			}
 		}
 	}
-	cluster.Status.Replicas = sts.Status.Replicas
-	observerReplicas := cluster.Status.Replicas - *cluster.Spec.ParticipantReplicas
-	if observerReplicas < 0 {
-		observerReplicas = 0
+	forgetPausedReplicas(cluster, sts)
+	observeStatefulSet(status, sts)
+	return reconcile.Continue()
+}
+
+func observeStatefulSet(status *v1.StorageGroupStatus, sts *appsv1.StatefulSet) {
+	status.Replicas = sts.Status.Replicas
+	status.ReadyReplicas = sts.Status.ReadyReplicas
+	status.UpdatedReplicas = sts.Status.UpdatedReplicas
+	if sts.Spec.Replicas != nil {
+		status.CurrentStatefulSetReplicas = *sts.Spec.Replicas
 	}
-	cluster.Status.ObserverReplicas = observerReplicas
-	cluster.Status.ParticipantReplicas = cluster.Status.Replicas - cluster.Status.ObserverReplicas
-	return r.reconcilePods(ctx, cluster)
+	if isRolledOut(sts) {
+		status.Version = sts.Annotations[v1.VersionAnnotation]
+	}
+}
+
+// isRolledOut tells whether every replica of the statefulset runs the latest template and is ready
+func isRolledOut(sts *appsv1.StatefulSet) bool {
+	return !sts.CreationTimestamp.IsZero() && sts.Status.ObservedGeneration >= sts.Generation && !isUpdating(sts)
 }
 
 func isUpdating(sts *appsv1.StatefulSet) bool {
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	v1 "fastdfs_operator/api/v1"
+	"fmt"
+
+	"github.com/fearlesschenc/operator-utils/pkg/reconcile"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UpdateStatus implements reconcile.StatusUpdater, it summarizes what the reconcile steps
+// observed into conditions and persists the status subresource when it changed
+func (r *FastDFSReconciler) UpdateStatus(ctx context.Context, object metav1.Object) error {
+	cluster, _ := object.(*v1.FastDFS)
+
+	latest := &v1.FastDFS{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(cluster), latest); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	r.summarizeStatus(cluster)
+	if equality.Semantic.DeepEqual(latest.Status, cluster.Status) {
+		return nil
+	}
+
+	latest.Status = cluster.Status
+	if err := r.Status().Update(ctx, latest); err != nil {
+		r.Log.Error(err, "failed to update cluster status")
+		return err
+	}
+	return nil
+}
+
+// ReconcileObservedGeneration is the last reconcile step, a generation is observed once every
+// step went through it, and its configuration was applied rather than the last good one kept
+func (r *FastDFSReconciler) ReconcileObservedGeneration(ctx context.Context, object metav1.Object) (reconcile.Result, error) {
+	cluster, _ := object.(*v1.FastDFS)
+	status := &cluster.Status
+	if status.ObservedGeneration == cluster.Generation ||
+		meta.IsStatusConditionFalse(status.Conditions, v1.ConditionConfigApplied) {
+		return reconcile.Continue()
+	}
+	now := metav1.Now()
+	status.LastScheduleTime = &now
+	status.ObservedGeneration = cluster.Generation
+	return reconcile.Continue()
+}
+
+func (r *FastDFSReconciler) summarizeStatus(cluster *v1.FastDFS) {
+	status := &cluster.Status
+	wasReady := meta.IsStatusConditionTrue(status.Conditions, v1.ConditionReady)
+
+	status.Selector = labels.SelectorFromSet(cluster.RoleMatchingLabels(v1.ServerRoleStorage)).String()
+
+	if cluster.Spec.Paused {
+		setSummaryCondition(cluster, v1.ConditionReady, metav1.ConditionFalse, "Paused", "cluster is paused")
+		setSummaryCondition(cluster, v1.ConditionProgressing, metav1.ConditionFalse, "Paused", "cluster is paused")
+		setSummaryCondition(cluster, v1.ConditionDegraded, metav1.ConditionFalse, "Paused", "cluster is paused")
+		status.Phase = v1.ClusterPhasePaused
+		return
+	}
+
+	desiredTrackers := *cluster.GetTrackerReplicas()
+	rolledOut := status.Tracker.Replicas == desiredTrackers && status.Tracker.UpdatedReplicas == desiredTrackers
+	ready := status.Tracker.ReadyReplicas == desiredTrackers
+	var desiredStorages int32
+	for _, group := range cluster.GetStorageGroups() {
+		var desired int32
+		if group.Replicas != nil {
+			desired = *group.Replicas
+		}
+		desiredStorages += desired
+		groupStatus := cluster.GetStorageGroupStatus(group.Name)
+		rolledOut = rolledOut && groupStatus.Replicas == desired && groupStatus.UpdatedReplicas == desired
+		ready = ready && groupStatus.ReadyReplicas == desired
+	}
+
+	// a generation is only rolled out once every step applied it and every server runs its release
+	applied := status.ObservedGeneration == cluster.Generation
+	upgraded := status.Version == cluster.Spec.Version
+	rolledOut = rolledOut && applied && upgraded
+
+	if rolledOut && ready {
+		setSummaryCondition(cluster, v1.ConditionReady, metav1.ConditionTrue, "AllReplicasReady", "all servers are ready")
+		setSummaryCondition(cluster, v1.ConditionProgressing, metav1.ConditionFalse, "RolloutComplete", "all servers are up to date")
+		setSummaryCondition(cluster, v1.ConditionDegraded, metav1.ConditionFalse, "AllReplicasReady", "all servers are ready")
+	} else {
+		message := fmt.Sprintf("trackers ready %d/%d, storages ready %d/%d",
+			status.Tracker.ReadyReplicas, desiredTrackers, status.ReadyReplicas, desiredStorages)
+		reason := "ReplicasNotReady"
+		if !upgraded {
+			reason = "Upgrading"
+			message = fmt.Sprintf("rolling out version %s, %s", cluster.Spec.Version, message)
+		}
+		if !applied {
+			reason = "GenerationNotApplied"
+			message = fmt.Sprintf("generation %d not applied yet, %s", cluster.Generation, message)
+		}
+		setSummaryCondition(cluster, v1.ConditionReady, metav1.ConditionFalse, reason, message)
+		if rolledOut {
+			setSummaryCondition(cluster, v1.ConditionProgressing, metav1.ConditionFalse, "RolloutComplete", "all servers are up to date")
+		} else {
+			setSummaryCondition(cluster, v1.ConditionProgressing, metav1.ConditionTrue, reason, message)
+		}
+
+		// readiness lost without any rollout going on means servers are failing
+		if rolledOut && (wasReady || status.Phase == v1.ClusterPhaseDegraded) {
+			setSummaryCondition(cluster, v1.ConditionDegraded, metav1.ConditionTrue, "ReplicasNotReady", message)
+		} else {
+			setSummaryCondition(cluster, v1.ConditionDegraded, metav1.ConditionFalse, "ReplicasNotReady", message)
+		}
+	}
+
+	switch {
+	case meta.IsStatusConditionTrue(status.Conditions, v1.ConditionReady):
+		status.Phase = v1.ClusterPhaseRunning
+	case meta.IsStatusConditionTrue(status.Conditions, v1.ConditionDegraded):
+		status.Phase = v1.ClusterPhaseDegraded
+	case status.Phase == "" || status.Phase == v1.ClusterPhaseCreating:
+		status.Phase = v1.ClusterPhaseCreating
+	default:
+		status.Phase = v1.ClusterPhaseUpdating
+	}
+
+}
+
+// setSummaryCondition sets a condition summarizing the whole cluster, it reflects
+// the last generation every reconcile step went through
+func setSummaryCondition(cluster *v1.FastDFS, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	setCondition(cluster, conditionType, status, reason, message)
+	meta.FindStatusCondition(cluster.Status.Conditions, conditionType).ObservedGeneration = cluster.Status.ObservedGeneration
+}
+
+func setCondition(cluster *v1.FastDFS, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: cluster.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	// SetStatusCondition keeps the generation of an existing condition
+	meta.FindStatusCondition(cluster.Status.Conditions, conditionType).ObservedGeneration = cluster.Generation
+}
@@ -0,0 +1,104 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// vectors are the tokens fdfs_http_gen_token of FastDFS common/fdfs_http_shared.c gives for
+// the file id without group name, key and timestamp, built against OpenSSL md5. The file ids
+// are given the way download urls carry them
+var vectors = []struct {
+	fileID    string
+	key       string
+	timestamp int64
+	token     string
+}{
+	{"M00/00/00/wKgBaFnbVKmAcJmGAAAB8cGkYTw123.jpg", "FastDFS1234567890", 1507612543, "1ef7a334690ab43f8efccf5f0b038c98"},
+	{"M00/00/00/rBAAAl-Dk_2AIB3qAAAAB9nCW0g527.txt", "FastDFS1234567890", 0, "bd6854b0e3d686101943e5a50543d2ac"},
+	{"M01/3A/7F/CgoKCmQ0a1iAe3GlAA1yZIb_RzQ.png", "a1b2c3d4e5f60718293a4b5c6d7e8f90", 1700000000, "891b6218cd08b04ed5569c15f00a39a2"},
+	{"group2/M02/1F/C0/wKgBaGV3ZqOAfC9hAAAAAJ6nNpA321.mp4", "FastDFS1234567890", 1600000000, "d2704616152ed990b05324e23a522cbf"},
+	{"/group1/M00/00/00/CgoKCmVAbcWAFbNkAAAHdjs5Ymc451.txt", strings.Repeat("0123456789abcdef", 8), 1696000000,
+		"7db39755bd11def352a06419015b9823"},
+	// signed in 2010, long expired
+	{"group1/M00/00/00/wKgBaFnbVKmAcJmGAAAB8cGkYTw123.jpg", "FastDFS1234567890", 1262304000, "6aa6f7db7665a18ab00fc6b5a1492826"},
+}
+
+func TestGenerate(t *testing.T) {
+	for _, v := range vectors {
+		if got := Generate(FileIDWithoutGroup(v.fileID), v.key, v.timestamp); got != v.token {
+			t.Errorf("Generate(%q, %q, %d) = %s, want %s", v.fileID, v.key, v.timestamp, got, v.token)
+		}
+	}
+}
+
+func TestVerifyAt(t *testing.T) {
+	v := vectors[0]
+	signedAt := time.Unix(v.timestamp, 0)
+	ttl := 600 * time.Second
+
+	tests := []struct {
+		name  string
+		key   string
+		token string
+		now   time.Time
+		want  error
+	}{
+		{"valid", v.key, v.token, signedAt.Add(time.Minute), nil},
+		{"valid at ttl", v.key, v.token, signedAt.Add(ttl), nil},
+		{"expired", v.key, v.token, signedAt.Add(ttl + time.Second), ErrTokenExpired},
+		{"wrong key", "another", v.token, signedAt, ErrInvalidToken},
+		{"malformed token", v.key, v.token[:31], signedAt, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyAt(v.fileID, tt.key, tt.token, v.timestamp, ttl, tt.now); err != tt.want {
+				t.Errorf("VerifyAt() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyAtWithoutTimestamp(t *testing.T) {
+	v := vectors[1]
+	if err := VerifyAt(v.fileID, v.key, v.token, v.timestamp, time.Second, time.Now()); err != nil {
+		t.Errorf("VerifyAt() = %v, want nil", err)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	v := vectors[5]
+	fileID := FileIDWithoutGroup(v.fileID)
+	if err := Verify(fileID, v.key, v.token, v.timestamp, 600*time.Second); err != ErrTokenExpired {
+		t.Errorf("Verify() = %v, want %v", err, ErrTokenExpired)
+	}
+	if err := VerifyAt(fileID, v.key, v.token, v.timestamp, 600*time.Second, time.Unix(v.timestamp, 0)); err != nil {
+		t.Errorf("VerifyAt() = %v, want nil", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	v := vectors[2]
+	query := Query(v.fileID, v.key, v.timestamp)
+	if got := query.Get(QueryToken); got != v.token {
+		t.Errorf("token = %s, want %s", got, v.token)
+	}
+	if got := query.Get(QueryTimestamp); got != "1700000000" {
+		t.Errorf("ts = %s, want 1700000000", got)
+	}
+}
+
+func TestFileIDWithoutGroup(t *testing.T) {
+	tests := map[string]string{
+		"group1/M00/00/00/xxx.jpg":  "M00/00/00/xxx.jpg",
+		"/group2/M0A/01/02/xxx.jpg": "M0A/01/02/xxx.jpg",
+		"M00/00/00/xxx.jpg":         "M00/00/00/xxx.jpg",
+		"group1/other/xxx.jpg":      "group1/other/xxx.jpg",
+	}
+	for fileID, want := range tests {
+		if got := FileIDWithoutGroup(fileID); got != want {
+			t.Errorf("FileIDWithoutGroup(%q) = %s, want %s", fileID, got, want)
+		}
+	}
+}
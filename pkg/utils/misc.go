@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"os"
 	"strings"
 	"unicode"
@@ -43,3 +45,12 @@ func GetEnv(key string, dft string) string {
 	}
 	return val
 }
+
+// RandomHex generates a random hex string of the given bytes, the string is twice as long
+func RandomHex(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}